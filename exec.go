@@ -0,0 +1,95 @@
+package sconfig
+
+// This file contains the backtick command substitution in values.
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+var errUnterminatedExec = errors.New("unterminated `")
+
+// defaultShell is used to run commands if Options.ExecShell is nil.
+var defaultShell = []string{"/bin/sh", "-c"}
+
+// expandValue expands all `..` and ${..} in s, depending on the options.
+//
+// The output of commands is used as-is: it's not expanded again.
+func expandValue(s string, opts Options) (string, error) {
+	if !opts.AllowExec {
+		return expandEnv(s, opts.RequireEnv)
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(s, '`')
+		if start == -1 {
+			break
+		}
+		end := strings.IndexByte(s[start+1:], '`')
+		if end == -1 {
+			return "", errUnterminatedExec
+		}
+		end += start + 1
+
+		if err := writeEnv(&b, s[:start], opts); err != nil {
+			return "", err
+		}
+		out, err := runCommand(s[start+1:end], opts)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(out)
+		s = s[end+1:]
+	}
+	if err := writeEnv(&b, s, opts); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func writeEnv(b *strings.Builder, s string, opts Options) error {
+	if !opts.ExpandEnv {
+		b.WriteString(s)
+		return nil
+	}
+	s, err := expandEnv(s, opts.RequireEnv)
+	b.WriteString(s)
+	return err
+}
+
+// runCommand runs cmd and returns the stdout with leading and trailing
+// whitespace removed.
+func runCommand(cmd string, opts Options) (string, error) {
+	shell := opts.ExecShell
+	if shell == nil {
+		shell = defaultShell
+	}
+	args := strings.Fields(cmd)
+	if len(shell) > 0 {
+		args = append(append([]string{}, shell...), cmd)
+	}
+	if len(args) == 0 || args[0] == "" {
+		return "", errors.New("empty command in ``")
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var stdout, stderr bytes.Buffer
+	c := exec.CommandContext(ctx, args[0], args[1:]...)
+	c.Stdout, c.Stderr = &stdout, &stderr
+	if err := c.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("running `%s`: %v: %s", cmd, err, msg)
+		}
+		return "", fmt.Errorf("running `%s`: %v", cmd, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,92 @@
+package sconfig
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestExpandExec(t *testing.T) {
+	os.Setenv("SCONFIG_SET", "value")
+	defer os.Unsetenv("SCONFIG_SET")
+
+	tests := []struct {
+		in      string
+		opts    Options
+		want    []string
+		wantErr string
+	}{
+		{"key `echo hello`", Options{AllowExec: true}, []string{"key", "hello"}, ""},
+		{"key `echo a  b` c", Options{AllowExec: true}, []string{"key", "a b", "c"}, ""},
+		{"key x`echo y`z", Options{AllowExec: true}, []string{"key", "xyz"}, ""},
+		{"key `echo; echo x; echo`", Options{AllowExec: true}, []string{"key", "x"}, ""},
+		{"key `echo a` `echo b`", Options{AllowExec: true}, []string{"key", "a", "b"}, ""},
+		{"key `echo ${SCONFIG_SET}`", Options{AllowExec: true}, []string{"key", "value"}, ""},
+
+		// Output isn't expanded again.
+		{"key `echo '${SCONFIG_SET}'`", Options{AllowExec: true, ExpandEnv: true},
+			[]string{"key", "${SCONFIG_SET}"}, ""},
+		{"key ${SCONFIG_SET}-`echo x`", Options{AllowExec: true, ExpandEnv: true},
+			[]string{"key", "value-x"}, ""},
+
+		// Shell
+		{"key `echo hello`", Options{AllowExec: true, ExecShell: []string{}},
+			[]string{"key", "hello"}, ""},
+		{"key `echo $HOME`", Options{AllowExec: true, ExecShell: []string{}},
+			[]string{"key", "$HOME"}, ""},
+		{"key `hello`", Options{AllowExec: true, ExecShell: []string{"echo", "-n"}},
+			[]string{"key", "hello"}, ""},
+
+		// Errors
+		{"key `echo hello", Options{AllowExec: true}, nil, "unterminated `"},
+		{"key `exit 3`", Options{AllowExec: true}, nil, "running `exit 3`: exit status 3"},
+		{"key `echo oops >&2; false`", Options{AllowExec: true}, nil, "exit status 1: oops"},
+		{"key ``", Options{AllowExec: true, ExecShell: []string{}}, nil, "empty command"},
+		{"key `sconfig-does-not-exist`", Options{AllowExec: true, ExecShell: []string{}}, nil,
+			"executable file not found"},
+
+		// Disabled
+		{"key `echo hello`", Options{}, []string{"key", "`echo", "hello`"}, ""},
+		{"key `echo hello`", Options{ExpandEnv: true}, []string{"key", "`echo", "hello`"}, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			out, err := splitLine(collapseWhitespace(tc.in, tc.opts.ExpandEnv), tc.opts)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParseExec(t *testing.T) {
+	f := testfile("hostname `echo example.com`\nport `expr 8000 + 80`")
+	defer rm(t, f)
+
+	var c struct {
+		Hostname string
+		Port     int64
+	}
+	err := ParseWith(&c, f, Options{AllowExec: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Hostname != "example.com" || c.Port != 8080 {
+		t.Errorf("wrong: %#v", c)
+	}
+
+	// Inert by default.
+	c.Hostname = ""
+	c.Port = 0
+	err = Parse(&c, f, nil)
+	if !errorContains(err, "must have exactly one value") {
+		t.Errorf("wrong error: %v", err)
+	}
+	if c.Hostname != "`echo example.com`" {
+		t.Errorf("wrong: %#v", c)
+	}
+}
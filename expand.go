@@ -0,0 +1,159 @@
+package sconfig
+
+// This file contains the expansion of environment variables in values.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var errUnterminated = errors.New("unterminated ${")
+
+// splitLine splits a line by spaces in to the key and values, and expands
+// environment variables and commands in the values if enabled.
+//
+// A ${..} or `..` is never split, even if it contains spaces.
+func splitLine(line string, opts Options) ([]string, error) {
+	if !opts.ExpandEnv && !opts.AllowExec {
+		return strings.Split(line, " "), nil
+	}
+
+	var (
+		v      []string
+		start  int
+		depth  int
+		inExec bool
+	)
+	for i := 0; i < len(line); i++ {
+		switch {
+		case opts.AllowExec && line[i] == '`':
+			inExec = !inExec
+		case inExec:
+		case !opts.ExpandEnv:
+			if line[i] == ' ' {
+				v = append(v, line[start:i])
+				start = i + 1
+			}
+		case isEscapedDollar(line, i):
+			i++
+		case strings.HasPrefix(line[i:], "${"):
+			depth++
+			i++
+		case line[i] == '}' && depth > 0:
+			depth--
+		case line[i] == ' ' && depth == 0:
+			v = append(v, line[start:i])
+			start = i + 1
+		}
+	}
+	v = append(v, line[start:])
+	if depth > 0 {
+		return v, errUnterminated
+	}
+	if inExec {
+		return v, errUnterminatedExec
+	}
+
+	for i := 1; i < len(v); i++ {
+		var err error
+		v[i], err = expandValue(v[i], opts)
+		if err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// expandEnv expands all ${..} and $VAR in s. Unset variables are an error if
+// requireEnv is set.
+func expandEnv(s string, requireEnv bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case isEscapedDollar(s, i):
+			b.WriteByte('$')
+			i++
+		case strings.HasPrefix(s[i:], "${"):
+			end := closingBrace(s, i+2)
+			if end == -1 {
+				return "", errUnterminated
+			}
+			val, err := expandVar(s[i+2:end], requireEnv)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(val)
+			i = end
+		case s[i] == '$' && i+1 < len(s) && isNameChar(s[i+1], true):
+			end := i + 2
+			for end < len(s) && isNameChar(s[end], false) {
+				end++
+			}
+			val, err := expandVar(s[i+1:end], requireEnv)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(val)
+			i = end - 1
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// expandVar expands the contents of a ${..}, which is either "VAR" or
+// "VAR ?? default".
+func expandVar(expr string, requireEnv bool) (string, error) {
+	name, def, hasDef := expr, "", false
+	if i := strings.Index(expr, "??"); i > -1 {
+		name, def, hasDef = expr[:i], expr[i+2:], true
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("empty variable name in ${" + expr + "}")
+	}
+
+	val, ok := os.LookupEnv(name)
+	if val == "" && hasDef {
+		return expandEnv(strings.TrimSpace(def), requireEnv)
+	}
+	if !ok && requireEnv {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return val, nil
+}
+
+// isNameChar reports if c can be used in a $VAR name; the first character
+// can't be a digit.
+func isNameChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+		(!first && c >= '0' && c <= '9')
+}
+
+// closingBrace finds the } that closes the ${ which ends at start, skipping
+// over any nested ${..}.
+func closingBrace(s string, start int) int {
+	depth := 1
+	for i := start; i < len(s); i++ {
+		switch {
+		case isEscapedDollar(s, i):
+			i++
+		case strings.HasPrefix(s[i:], "${"):
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isEscapedDollar(s string, i int) bool {
+	return s[i] == '\\' && i+1 < len(s) && s[i+1] == '$'
+}
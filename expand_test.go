@@ -0,0 +1,125 @@
+package sconfig
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	os.Setenv("SCONFIG_SET", "value")
+	os.Setenv("SCONFIG_SPACE", "a b")
+	os.Setenv("SCONFIG_EMPTY", "")
+	os.Unsetenv("SCONFIG_UNSET")
+	defer func() {
+		os.Unsetenv("SCONFIG_SET")
+		os.Unsetenv("SCONFIG_SPACE")
+		os.Unsetenv("SCONFIG_EMPTY")
+	}()
+
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr string
+	}{
+		{`key ${SCONFIG_SET}`, []string{"key", "value"}, ""},
+		{`key x${SCONFIG_SET}x y`, []string{"key", "xvaluex", "y"}, ""},
+		{`key ${SCONFIG_UNSET}`, []string{"key", ""}, ""},
+		{`key ${SCONFIG_SPACE}`, []string{"key", "a b"}, ""},
+
+		// Defaults
+		{`key ${SCONFIG_SET ?? 8080}`, []string{"key", "value"}, ""},
+		{`key ${SCONFIG_UNSET ?? 8080}`, []string{"key", "8080"}, ""},
+		{`key ${SCONFIG_EMPTY ?? 8080}`, []string{"key", "8080"}, ""},
+		{`key ${SCONFIG_UNSET??8080}`, []string{"key", "8080"}, ""},
+		{`key ${SCONFIG_UNSET ?? two words} x`, []string{"key", "two words", "x"}, ""},
+		{`key ${SCONFIG_UNSET ?? ${SCONFIG_SET}}`, []string{"key", "value"}, ""},
+		{`key ${SCONFIG_UNSET ?? ${SCONFIG_EMPTY ?? deep}}`, []string{"key", "deep"}, ""},
+		{`key ${SCONFIG_UNSET ?? }`, []string{"key", ""}, ""},
+
+		// Escaping
+		{`key \${SCONFIG_SET}`, []string{"key", "${SCONFIG_SET}"}, ""},
+		{`key ${SCONFIG_UNSET ?? \${x}}`, []string{"key", "${x}"}, ""},
+		{`key \$SCONFIG_SET $ $5`, []string{"key", "$SCONFIG_SET", "$", "$5"}, ""},
+
+		// $VAR
+		{`key $SCONFIG_SET`, []string{"key", "value"}, ""},
+		{`key x$SCONFIG_SET.y`, []string{"key", "xvalue.y"}, ""},
+		{`key $SCONFIG_SPACE x`, []string{"key", "a b", "x"}, ""},
+		{`key $SCONFIG_UNSET`, []string{"key", ""}, ""},
+
+		// Errors
+		{`key ${SCONFIG_SET`, nil, "unterminated ${"},
+		{`key ${ ?? x}`, nil, "empty variable name"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			out, err := splitLine(collapseWhitespace(tc.in, true), Options{ExpandEnv: true})
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParseExpandEnv(t *testing.T) {
+	os.Setenv("SCONFIG_PORT", "9000")
+	defer os.Unsetenv("SCONFIG_PORT")
+
+	f := testfile("port ${SCONFIG_PORT ?? 8080}\nhost ${SCONFIG_HOST ?? localhost}\nprice \\$5")
+	defer rm(t, f)
+
+	var c struct {
+		Port  int64
+		Host  string
+		Price string
+	}
+	err := ParseWith(&c, f, Options{ExpandEnv: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 9000 || c.Host != "localhost" || c.Price != "$5" {
+		t.Errorf("wrong: %#v", c)
+	}
+
+	// Not expanded by default.
+	err = Parse(&c, f, nil)
+	if err == nil {
+		t.Fatal("err is nil")
+	}
+	if !errorContains(err, "must have exactly one value") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestRequireEnv(t *testing.T) {
+	os.Setenv("SCONFIG_EMPTY", "")
+	defer os.Unsetenv("SCONFIG_EMPTY")
+
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr string
+	}{
+		{`key ${SCONFIG_UNSET}`, nil, "environment variable SCONFIG_UNSET is not set"},
+		{`key $SCONFIG_UNSET`, nil, "environment variable SCONFIG_UNSET is not set"},
+		{`key ${SCONFIG_UNSET ?? x}`, []string{"key", "x"}, ""},
+		{`key $SCONFIG_EMPTY`, []string{"key", ""}, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			out, err := splitLine(collapseWhitespace(tc.in, true), Options{ExpandEnv: true, RequireEnv: true})
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
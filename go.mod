@@ -1,3 +1,5 @@
 module zgo.at/sconfig
 
-go 1.13
+go 1.15
+
+require github.com/google/uuid v1.6.0
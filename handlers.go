@@ -2,8 +2,10 @@ package sconfig
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // This file contains the default handler functions for Go's primitives.
@@ -14,19 +16,27 @@ func init() {
 
 func defaultTypeHandlers() {
 	typeHandlers = map[string][]TypeHandler{
-		"string":            {handleString},
-		"bool":              {handleBool},
-		"float32":           {ValidateSingleValue(), handleFloat32},
-		"float64":           {ValidateSingleValue(), handleFloat64},
-		"int64":             {ValidateSingleValue(), handleInt64},
-		"uint64":            {ValidateSingleValue(), handleUint64},
-		"[]string":          {ValidateValueLimit(1, 0), handleStringSlice},
-		"[]bool":            {ValidateValueLimit(1, 0), handleBoolSlice},
-		"[]float32":         {ValidateValueLimit(1, 0), handleFloat32Slice},
-		"[]float64":         {ValidateValueLimit(1, 0), handleFloat64Slice},
-		"[]int64":           {ValidateValueLimit(1, 0), handleInt64Slice},
-		"[]uint64":          {ValidateValueLimit(1, 0), handleUint64Slice},
-		"map[string]string": {ValidateValueLimit(2, 0), handleStringMap},
+		"string":              {handleString},
+		"bool":                {ValidateAtMostOneValue(), handleBool},
+		"float32":             {ValidateSingleValue(), handleFloat32},
+		"float64":             {ValidateSingleValue(), handleFloat64},
+		"complex64":           {ValidateSingleValue(), handleComplex64},
+		"complex128":          {ValidateSingleValue(), handleComplex128},
+		"int64":               {ValidateSingleValue(), HandleInt64},
+		"uint64":              {ValidateSingleValue(), handleUint64},
+		"[]string":            {ValidateValueLimit(1, 0), handleStringSlice},
+		"[]bool":              {ValidateValueLimit(1, 0), handleBoolSlice},
+		"[]float32":           {ValidateValueLimit(1, 0), handleFloat32Slice},
+		"[]float64":           {ValidateValueLimit(1, 0), handleFloat64Slice},
+		"[]complex64":         {ValidateValueLimit(1, 0), handleComplex64Slice},
+		"[]complex128":        {ValidateValueLimit(1, 0), handleComplex128Slice},
+		"[]int64":             {ValidateValueLimit(1, 0), handleInt64Slice},
+		"[]uint64":            {ValidateValueLimit(1, 0), handleUint64Slice},
+		"map[string]string":   {ValidateValueLimit(2, 0), handleStringMap},
+		"map[string][]string": {ValidateValueLimit(2, 0), handleStringSliceMap},
+		"[]sconfig.Pair":      {ValidateValueLimit(1, 0), handlePairSlice},
+		"time.Duration":       {ValidateSingleValue(), HandleDuration},
+		"[]time.Duration":     {ValidateValueLimit(1, 0), handleDurationSlice},
 	}
 }
 
@@ -53,23 +63,139 @@ func parseBool(v string) (bool, error) {
 	}
 }
 
+// HandleNumericBool is a type handler for booleans which accepts any integer in
+// addition to the textual forms: "0" is false, and every other number ("1",
+// "2", "-1") is true.
+//
+// This isn't used by default as "2" is more likely to be a mistake than an
+// intentional "true". To use it instead of the default bool handler:
+//
+//     sconfig.RegisterType("bool", sconfig.ValidateAtMostOneValue(), sconfig.HandleNumericBool)
+//     sconfig.RegisterType("[]bool", sconfig.ValidateValueLimit(1, 0), sconfig.HandleNumericBoolSlice)
+//
+// RegisterType() replaces the existing handler, so this can't be combined with
+// another bool handler.
+func HandleNumericBool(v []string) (interface{}, error) {
+	r, err := parseNumericBool(strings.Join(v, ""))
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// HandleNumericBoolSlice is like HandleNumericBool, but for []bool.
+func HandleNumericBoolSlice(v []string) (interface{}, error) {
+	a := make([]bool, len(v))
+	for i := range v {
+		r, err := parseNumericBool(v[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = r
+	}
+	return a, nil
+}
+
+func parseNumericBool(v string) (bool, error) {
+	r, err := parseBool(v)
+	if err == nil {
+		return r, nil
+	}
+	n, nErr := strconv.ParseInt(numeric(v), 10, 64)
+	if nErr != nil {
+		return false, err
+	}
+	return n != 0, nil
+}
+
+// HandleRune is a type handler for runes which accepts a single character in
+// addition to a number; "sep ;" and "sep 59" both set a rune field to ';'.
+//
+// A rune is an alias for int32, so this can't be the default without breaking
+// numeric int32 fields. To use it for all int32 fields:
+//
+//     sconfig.RegisterType("int32", sconfig.ValidateSingleValue(), sconfig.HandleRune)
+//
+// The digits 0 to 9 are ambiguous: "sep 5" is the number 5, not the character
+// '5' (53). Use the number for these characters, and "\#" for '#'.
+func HandleRune(v []string) (interface{}, error) {
+	s := strings.Join(v, "")
+	if r := []rune(s); len(r) == 1 && (r[0] < '0' || r[0] > '9') {
+		return r[0], nil
+	}
+	n, err := strconv.ParseInt(numeric(s), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %q as a character or number", s)
+	}
+	return rune(n), nil
+}
+
+// HandleByte is like HandleRune, but for bytes (uint8); only ASCII characters
+// are accepted:
+//
+//     sconfig.RegisterType("uint8", sconfig.ValidateSingleValue(), sconfig.HandleByte)
+func HandleByte(v []string) (interface{}, error) {
+	s := strings.Join(v, "")
+	if len(s) == 1 && (s[0] < '0' || s[0] > '9') && s[0] < 0x80 {
+		return s[0], nil
+	}
+	n, err := strconv.ParseUint(numeric(s), 10, 8)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %q as an ASCII character or number", s)
+	}
+	return byte(n), nil
+}
+
+// numeric normalizes a number before it's passed to strconv: surrounding
+// whitespace is trimmed and a single leading "+" is removed, so that "+8080"
+// works for unsigned integers too (strconv.ParseUint doesn't accept a sign).
+//
+// Nothing else is changed; "++1", "+-1", or "1+" are still errors.
+func numeric(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) > 1 && v[0] == '+' && v[1] != '+' && v[1] != '-' {
+		v = v[1:]
+	}
+	return v
+}
+
 func handleFloat32(v []string) (interface{}, error) {
-	r, err := strconv.ParseFloat(strings.Join(v, ""), 32)
+	r, err := strconv.ParseFloat(numeric(strings.Join(v, "")), 32)
 	if err != nil {
 		return nil, err
 	}
 	return float32(r), nil
 }
 func handleFloat64(v []string) (interface{}, error) {
-	r, err := strconv.ParseFloat(strings.Join(v, ""), 64)
+	r, err := strconv.ParseFloat(numeric(strings.Join(v, "")), 64)
 	if err != nil {
 		return nil, err
 	}
 	return r, nil
 }
 
-func handleInt64(v []string) (interface{}, error) {
-	r, err := strconv.ParseInt(strings.Join(v, ""), 10, 64)
+func handleComplex64(v []string) (interface{}, error) {
+	r, err := strconv.ParseComplex(numeric(strings.Join(v, "")), 64)
+	if err != nil {
+		return nil, err
+	}
+	return complex64(r), nil
+}
+func handleComplex128(v []string) (interface{}, error) {
+	r, err := strconv.ParseComplex(numeric(strings.Join(v, "")), 128)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// HandleInt64 is the type handler for int64. It's registered by default; it's
+// exported so validators can be added:
+//
+//     sconfig.RegisterType("int64", sconfig.ValidateSingleValue(),
+//         sconfig.ValidateIntRange(1, 65535), sconfig.HandleInt64)
+func HandleInt64(v []string) (interface{}, error) {
+	r, err := strconv.ParseInt(numeric(strings.Join(v, "")), 10, 64)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +203,7 @@ func handleInt64(v []string) (interface{}, error) {
 }
 
 func handleUint64(v []string) (interface{}, error) {
-	r, err := strconv.ParseUint(strings.Join(v, ""), 10, 64)
+	r, err := strconv.ParseUint(numeric(strings.Join(v, "")), 10, 64)
 	if err != nil {
 		return nil, err
 	}
@@ -103,7 +229,7 @@ func handleBoolSlice(v []string) (interface{}, error) {
 func handleFloat32Slice(v []string) (interface{}, error) {
 	a := make([]float32, len(v))
 	for i := range v {
-		r, err := strconv.ParseFloat(v[i], 32)
+		r, err := strconv.ParseFloat(numeric(v[i]), 32)
 		if err != nil {
 			return nil, err
 		}
@@ -115,7 +241,31 @@ func handleFloat32Slice(v []string) (interface{}, error) {
 func handleFloat64Slice(v []string) (interface{}, error) {
 	a := make([]float64, len(v))
 	for i := range v {
-		r, err := strconv.ParseFloat(v[i], 64)
+		r, err := strconv.ParseFloat(numeric(v[i]), 64)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = r
+	}
+	return a, nil
+}
+
+func handleComplex64Slice(v []string) (interface{}, error) {
+	a := make([]complex64, len(v))
+	for i := range v {
+		r, err := strconv.ParseComplex(numeric(v[i]), 64)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = complex64(r)
+	}
+	return a, nil
+}
+
+func handleComplex128Slice(v []string) (interface{}, error) {
+	a := make([]complex128, len(v))
+	for i := range v {
+		r, err := strconv.ParseComplex(numeric(v[i]), 128)
 		if err != nil {
 			return nil, err
 		}
@@ -127,7 +277,7 @@ func handleFloat64Slice(v []string) (interface{}, error) {
 func handleInt64Slice(v []string) (interface{}, error) {
 	a := make([]int64, len(v))
 	for i := range v {
-		r, err := strconv.ParseInt(v[i], 10, 64)
+		r, err := strconv.ParseInt(numeric(v[i]), 10, 64)
 		if err != nil {
 			return nil, err
 		}
@@ -139,7 +289,7 @@ func handleInt64Slice(v []string) (interface{}, error) {
 func handleUint64Slice(v []string) (interface{}, error) {
 	a := make([]uint64, len(v))
 	for i := range v {
-		r, err := strconv.ParseUint(v[i], 10, 64)
+		r, err := strconv.ParseUint(numeric(v[i]), 10, 64)
 		if err != nil {
 			return nil, err
 		}
@@ -148,6 +298,105 @@ func handleUint64Slice(v []string) (interface{}, error) {
 	return a, nil
 }
 
+// HandleDuration is the type handler for time.Duration, which accepts
+// everything time.ParseDuration() does plus the units "d", "w", "mo", and "y"
+// (e.g. "1w3d"). It's registered by default; it's exported so validators can
+// be added:
+//
+//     sconfig.RegisterType("time.Duration", sconfig.ValidateSingleValue(),
+//         sconfig.ValidateMinDuration(time.Second), sconfig.HandleDuration)
+func HandleDuration(v []string) (interface{}, error) {
+	r, err := parseDuration(strings.Join(v, ""))
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func handleDurationSlice(v []string) (interface{}, error) {
+	a := make([]time.Duration, len(v))
+	for i := range v {
+		r, err := parseDuration(v[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = r
+	}
+	return a, nil
+}
+
+// Units for parseDuration() in addition to those time.ParseDuration()
+// understands. These are fixed lengths and not calendar-aware: a day is always
+// 24 hours, a month always 30 days, and a year always 365 days.
+var durationUnits = map[string]time.Duration{
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+	"mo": 30 * 24 * time.Hour,
+	"y":  365 * 24 * time.Hour,
+}
+
+// parseDuration is like time.ParseDuration(), but also accepts the units in
+// durationUnits, and they can be mixed: "1w3d", "1y6mo", "1d12h".
+func parseDuration(v string) (time.Duration, error) {
+	s := numeric(v)
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+
+	if s == "" {
+		return 0, fmt.Errorf("unable to parse %q as a duration", v)
+	}
+
+	var (
+		total time.Duration
+		std   string // Components time.ParseDuration() can deal with.
+	)
+	for s != "" {
+		n := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if n == -1 {
+			n = len(s)
+		}
+		if n == 0 {
+			return 0, fmt.Errorf("unable to parse %q as a duration", v)
+		}
+		u := strings.IndexFunc(s[n:], func(r rune) bool { return (r >= '0' && r <= '9') || r == '.' })
+		if u == -1 {
+			u = len(s) - n
+		}
+		num, unit := s[:n], s[n:n+u]
+
+		if d, ok := durationUnits[unit]; ok {
+			f, err := strconv.ParseFloat(num, 64)
+			if err != nil {
+				return 0, fmt.Errorf("unable to parse %q as a duration", v)
+			}
+			if f*float64(d) > math.MaxInt64-float64(total) {
+				return 0, fmt.Errorf("unable to parse %q as a duration", v)
+			}
+			total += time.Duration(f * float64(d))
+		} else {
+			std += num + unit
+		}
+		s = s[n+u:]
+	}
+
+	if std != "" {
+		d, err := time.ParseDuration(std)
+		if err != nil {
+			return 0, fmt.Errorf("unable to parse %q as a duration", v)
+		}
+		if d > math.MaxInt64-total {
+			return 0, fmt.Errorf("unable to parse %q as a duration", v)
+		}
+		total += d
+	}
+	if neg {
+		total = -total
+	}
+	return total, nil
+}
+
 func handleStringMap(v []string) (interface{}, error) {
 	if len(v)%2 != 0 {
 		return nil, fmt.Errorf("uneven number of arguments: %d", len(v))
@@ -165,3 +414,39 @@ func handleStringMap(v []string) (interface{}, error) {
 
 	return a, nil
 }
+
+// handleStringSliceMap uses the first value as the key, and the rest as the
+// value.
+func handleStringSliceMap(v []string) (interface{}, error) {
+	return map[string][]string{v[0]: v[1:]}, nil
+}
+
+// Pair is a key/value pair. A []Pair can be used as a map that preserves the
+// order; every value is a "key=value" pair:
+//
+//     headers x-frame-options=deny x-xss-protection=0
+//     use auth logging ratelimit
+//
+// The value is empty for values without a "=", so "use auth" is
+// Pair{Key: "auth"}. Only the first "=" is used to split the key and value;
+// "a=b=c" is Pair{Key: "a", Value: "b=c"}.
+//
+// Like other slices, multiple lines for the same key are appended to the slice.
+type Pair struct {
+	Key, Value string
+}
+
+func handlePairSlice(v []string) (interface{}, error) {
+	a := make([]Pair, len(v))
+	for i := range v {
+		kv := strings.SplitN(v[i], "=", 2)
+		if kv[0] == "" {
+			return nil, fmt.Errorf("empty key in %q", v[i])
+		}
+		a[i].Key = kv[0]
+		if len(kv) == 2 {
+			a[i].Value = kv[1]
+		}
+	}
+	return a, nil
+}
@@ -1,6 +1,13 @@
 // Package big contains handlers for parsing values with the math/big package.
 //
-// It currently implements the big.Int and big.Float types.
+// It currently implements the big.Int, big.Float, and big.Rat types.
+//
+// A big.Rat can be written as a fraction ("1/3") or as a decimal ("0.25").
+//
+// A big.Int and the elements of a []*big.Int detect the base from the prefix:
+// "0x" for hexadecimal, "0o" or "0" for octal, and "0b" for binary; so "nonces
+// 0xff 255 0o10" is []*big.Int{255, 255, 8}. Note that a leading zero means
+// octal, so "010" is 8 and not 10.
 package big
 
 import (
@@ -12,20 +19,24 @@ import (
 )
 
 var (
-	errHandleInt   = "unable to convert %v to big.Int"
-	errHandleFloat = "unable to convert %v to big.Float"
+	errHandleInt      = "unable to convert %v to big.Int"
+	errHandleFloat    = "unable to convert %v to big.Float"
+	errHandleRat      = "unable to convert %v to big.Rat"
+	errHandleIntIndex = "unable to convert %v to big.Int at position %d"
 )
 
 func init() {
 	sconfig.RegisterType("*big.Int", sconfig.ValidateSingleValue(), handleInt)
 	sconfig.RegisterType("*big.Float", sconfig.ValidateSingleValue(), handleFloat)
+	sconfig.RegisterType("*big.Rat", sconfig.ValidateSingleValue(), handleRat)
 	sconfig.RegisterType("[]*big.Int", sconfig.ValidateValueLimit(1, 0), handleIntSlice)
 	sconfig.RegisterType("[]*big.Float", sconfig.ValidateValueLimit(1, 0), handleFloatSlice)
+	sconfig.RegisterType("[]*big.Rat", sconfig.ValidateValueLimit(1, 0), handleRatSlice)
 }
 
 func handleInt(v []string) (interface{}, error) {
 	n := big.Int{}
-	z, success := n.SetString(strings.Join(v, ""), 10)
+	z, success := n.SetString(strings.Join(v, ""), 0)
 	if !success {
 		return nil, fmt.Errorf(errHandleInt, strings.Join(v, ""))
 	}
@@ -41,13 +52,22 @@ func handleFloat(v []string) (interface{}, error) {
 	return z, nil
 }
 
+func handleRat(v []string) (interface{}, error) {
+	n := big.Rat{}
+	z, success := n.SetString(strings.Join(v, ""))
+	if !success {
+		return nil, fmt.Errorf(errHandleRat, strings.Join(v, ""))
+	}
+	return z, nil
+}
+
 func handleIntSlice(v []string) (interface{}, error) {
 	a := make([]*big.Int, len(v))
 	for i := range v {
 		a[i] = &big.Int{}
-		z, success := a[i].SetString(v[i], 10)
+		z, success := a[i].SetString(v[i], 0)
 		if !success {
-			return nil, fmt.Errorf(errHandleInt, v[i])
+			return nil, fmt.Errorf(errHandleIntIndex, v[i], i+1)
 		}
 		a[i] = z
 	}
@@ -66,3 +86,16 @@ func handleFloatSlice(v []string) (interface{}, error) {
 	}
 	return a, nil
 }
+
+func handleRatSlice(v []string) (interface{}, error) {
+	a := make([]*big.Rat, len(v))
+	for i := range v {
+		a[i] = &big.Rat{}
+		z, success := a[i].SetString(v[i])
+		if !success {
+			return nil, fmt.Errorf(errHandleRat, v[i])
+		}
+		a[i] = z
+	}
+	return a, nil
+}
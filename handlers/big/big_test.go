@@ -2,7 +2,10 @@ package big
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math/big"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -21,15 +24,31 @@ func TestMath(t *testing.T) {
 		{handleInt, []string{"9223372036854775808"},
 			big.NewInt(0).Add(big.NewInt(9223372036854775807), big.NewInt(1)),
 			""},
+		{handleInt, []string{"0xff"}, big.NewInt(255), ""},
+		{handleInt, []string{"010"}, big.NewInt(8), ""},
+		{handleInt, []string{"0b11"}, big.NewInt(3), ""},
+		{handleInt, []string{"0xfg"}, nil, fmt.Sprintf(errHandleInt, "0xfg")},
 
 		{handleFloat, []string{"42"}, big.NewFloat(42), ""},
 		{handleFloat, []string{"42.1"}, big.NewFloat(42.1), ""},
 		{handleFloat, []string{"4x"}, nil, fmt.Sprintf(errHandleFloat, "4x")},
 
+		{handleRat, []string{"1/3"}, big.NewRat(1, 3), ""},
+		{handleRat, []string{"2/4"}, big.NewRat(1, 2), ""},
+		{handleRat, []string{"0.25"}, big.NewRat(1, 4), ""},
+		{handleRat, []string{"-3"}, big.NewRat(-3, 1), ""},
+		{handleRat, []string{"x/y"}, nil, fmt.Sprintf(errHandleRat, "x/y")},
+		{handleRat, []string{"1/0"}, nil, fmt.Sprintf(errHandleRat, "1/0")},
+
 		{handleIntSlice, []string{"100", "101"}, []*big.Int{big.NewInt(100), big.NewInt(101)}, ""},
-		{handleIntSlice, []string{"100", "10x1"}, nil, "unable to convert 10x1 to big.Int"},
+		{handleIntSlice, []string{"100", "10x1"}, nil, "unable to convert 10x1 to big.Int at position 2"},
+		{handleIntSlice, []string{"0xff", "255", "0o10", "010", "0b11"},
+			[]*big.Int{big.NewInt(255), big.NewInt(255), big.NewInt(8), big.NewInt(8), big.NewInt(3)}, ""},
+		{handleIntSlice, []string{"0xff", "0xfg"}, nil, "unable to convert 0xfg to big.Int at position 2"},
 		{handleFloatSlice, []string{"100", "101"}, []*big.Float{big.NewFloat(100), big.NewFloat(101)}, ""},
 		{handleFloatSlice, []string{"100", "10x1"}, nil, "unable to convert 10x1 to big.Float"},
+		{handleRatSlice, []string{"1/3", "0.5"}, []*big.Rat{big.NewRat(1, 3), big.NewRat(1, 2)}, ""},
+		{handleRatSlice, []string{"1/3", "x/y"}, nil, "unable to convert x/y to big.Rat"},
 	}
 
 	for i, tc := range cases {
@@ -39,15 +58,48 @@ func TestMath(t *testing.T) {
 				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
 			}
 
+			// %#v prints the pointers for []*big.Rat, as it doesn't
+			// implement fmt.Formatter.
 			o := fmt.Sprintf("%#v", out)
 			w := fmt.Sprintf("%#v", tc.want)
-			if o != w {
+			if o != w && !reflect.DeepEqual(out, tc.want) {
 				t.Errorf("\nwant: %#v (%[1]T)\nout:  %#v (%[2]T)\n", tc.want, out)
 			}
 		})
 	}
 }
 
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("int 9223372036854775808\nhex 0xff\nfloat 1.5\nrat 1/3\n" +
+		"ints 1 0x10\nfloats 1.5 2\nrats 1/3 0.5\n")
+	fp.Close()
+
+	var c struct {
+		Int    *big.Int
+		Hex    *big.Int
+		Float  *big.Float
+		Rat    *big.Rat
+		Ints   []*big.Int
+		Floats []*big.Float
+		Rats   []*big.Rat
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := fmt.Sprintf("%v %v %v %v %v %v %v", c.Int, c.Hex, c.Float, c.Rat, c.Ints, c.Floats, c.Rats)
+	want := "9223372036854775808 255 1.5 1/3 [1 16] [1.5 2] [1/3 1/2]"
+	if out != want {
+		t.Errorf("\nwant: %s\nout:  %s", want, out)
+	}
+}
+
 func errorContains(out error, want string) bool {
 	if out == nil {
 		return want == ""
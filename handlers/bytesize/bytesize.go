@@ -0,0 +1,124 @@
+// Package bytesize contains handlers for sizes in bytes.
+//
+// It implements the Bytes type, which is written as a number with an optional
+// unit:
+//
+//     max-upload 10MB
+//     buffer     64KiB
+//     limit      1.5GB
+//     block      512
+//
+// The units are case-insensitive, and both SI (powers of 1000) and IEC (powers
+// of 1024) units are supported:
+//
+//     B                          1
+//     KB   MB   GB   TB   PB     1000, 1000², etc.
+//     KiB  MiB  GiB  TiB  PiB    1024, 1024², etc.
+//
+// A number without a unit is in bytes. Fractions are allowed, but the result is
+// rounded down to whole bytes.
+package bytesize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"zgo.at/sconfig"
+)
+
+// Bytes is a size in bytes.
+type Bytes int64
+
+// Units.
+const (
+	B   Bytes = 1
+	KB        = 1000 * B
+	MB        = 1000 * KB
+	GB        = 1000 * MB
+	TB        = 1000 * GB
+	PB        = 1000 * TB
+	KiB       = 1024 * B
+	MiB       = 1024 * KiB
+	GiB       = 1024 * MiB
+	TiB       = 1024 * GiB
+	PiB       = 1024 * TiB
+)
+
+var units = map[string]Bytes{
+	"": B, "b": B,
+	"kb": KB, "mb": MB, "gb": GB, "tb": TB, "pb": PB,
+	"kib": KiB, "mib": MiB, "gib": GiB, "tib": TiB, "pib": PiB,
+}
+
+// String formats the size with the largest unit that it's a whole multiple
+// of, e.g. "64KiB" or "10MB".
+func (b Bytes) String() string {
+	for _, u := range []struct {
+		name string
+		size Bytes
+	}{
+		{"PiB", PiB}, {"PB", PB}, {"TiB", TiB}, {"TB", TB}, {"GiB", GiB},
+		{"GB", GB}, {"MiB", MiB}, {"MB", MB}, {"KiB", KiB}, {"KB", KB},
+	} {
+		if b != 0 && b%u.size == 0 {
+			return strconv.FormatInt(int64(b/u.size), 10) + u.name
+		}
+	}
+	return strconv.FormatInt(int64(b), 10) + "B"
+}
+
+func init() {
+	sconfig.RegisterType("bytesize.Bytes", sconfig.ValidateSingleValue(), handleBytes)
+	sconfig.RegisterType("[]bytesize.Bytes", sconfig.ValidateValueLimit(1, 0), handleBytesSlice)
+}
+
+func handleBytes(v []string) (interface{}, error) {
+	return parse(v[0])
+}
+
+func handleBytesSlice(v []string) (interface{}, error) {
+	a := make([]Bytes, len(v))
+	for i := range v {
+		b, err := parse(v[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = b
+	}
+	return a, nil
+}
+
+func parse(s string) (Bytes, error) {
+	n := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if n == -1 {
+		n = len(s)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	unit, ok := units[strings.ToLower(s[n:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+
+	// Parse integers as such, so large values don't lose precision.
+	if i, err := strconv.ParseInt(s[:n], 10, 64); err == nil {
+		if i > math.MaxInt64/int64(unit) {
+			return 0, fmt.Errorf("invalid size %q: too large", s)
+		}
+		return Bytes(i) * unit, nil
+	}
+
+	f, err := strconv.ParseFloat(s[:n], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	f *= float64(unit)
+	if f >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size %q: too large", s)
+	}
+	return Bytes(f), nil
+}
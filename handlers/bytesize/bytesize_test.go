@@ -0,0 +1,115 @@
+package bytesize
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"zgo.at/sconfig"
+)
+
+func TestBytes(t *testing.T) {
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    interface{}
+		wantErr string
+	}{
+		{handleBytes, []string{"512"}, Bytes(512), ""},
+		{handleBytes, []string{"0"}, Bytes(0), ""},
+		{handleBytes, []string{"1B"}, B, ""},
+		{handleBytes, []string{"10MB"}, 10 * MB, ""},
+		{handleBytes, []string{"10mb"}, 10 * MB, ""},
+		{handleBytes, []string{"64KiB"}, 64 * KiB, ""},
+		{handleBytes, []string{"64kib"}, 64 * KiB, ""},
+		{handleBytes, []string{"2GiB"}, 2 * GiB, ""},
+		{handleBytes, []string{"1.5GB"}, 1500 * MB, ""},
+		{handleBytes, []string{"0.5KiB"}, Bytes(512), ""},
+		{handleBytes, []string{"8PiB"}, 8 * PiB, ""},
+
+		{handleBytes, []string{"10XB"}, nil, `invalid size "10XB"`},
+		{handleBytes, []string{"MB"}, nil, `invalid size "MB"`},
+		{handleBytes, []string{"1..5MB"}, nil, `invalid size "1..5MB"`},
+		{handleBytes, []string{"-1"}, nil, `invalid size "-1"`},
+		{handleBytes, []string{"9000PiB"}, nil, `invalid size "9000PiB": too large`},
+		{handleBytes, []string{"9000.5PiB"}, nil, `invalid size "9000.5PiB": too large`},
+
+		{handleBytesSlice, []string{"1KB", "1KiB"}, []Bytes{KB, KiB}, ""},
+		{handleBytesSlice, []string{"1KB", "1x"}, nil, `invalid size "1x"`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	cases := []struct {
+		in   Bytes
+		want string
+	}{
+		{0, "0B"},
+		{512, "512B"},
+		{KB, "1KB"},
+		{64 * KiB, "64KiB"},
+		{10 * MB, "10MB"},
+		{1500 * MB, "1500MB"},
+		{2 * GiB, "2GiB"},
+		{1025, "1025B"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.want, func(t *testing.T) {
+			if out := tc.in.String(); out != tc.want {
+				t.Errorf("\nwant: %q\nout:  %q", tc.want, out)
+			}
+			if b, err := parse(tc.want); err != nil || b != tc.in {
+				t.Errorf("doesn't round-trip: %v %v", b, err)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_bytesize")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("max-upload 10MB\nbuffer 64KiB\nlimits 1KB 2KB\n")
+	fp.Close()
+
+	var c struct {
+		MaxUpload Bytes
+		Buffer    Bytes
+		Limits    []Bytes
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MaxUpload != 10*MB || c.Buffer != 64*KiB || !reflect.DeepEqual(c.Limits, []Bytes{KB, 2 * KB}) {
+		t.Errorf("wrong: %v", c)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
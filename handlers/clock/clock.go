@@ -0,0 +1,89 @@
+// Package clock contains handlers for a time of day, without a date.
+//
+// It implements the Time type, which is written as "HH:MM" or "HH:MM:SS" in a
+// 24-hour clock:
+//
+//     start 09:05
+//     end   23:59:59
+//
+// Hours must be between 0 and 23, and minutes and seconds between 0 and 59.
+package clock
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"zgo.at/sconfig"
+)
+
+// Time is a time of day, as the number of seconds since midnight.
+type Time int
+
+// New creates a new Time.
+func New(hour, min, sec int) Time {
+	return Time(hour*3600 + min*60 + sec)
+}
+
+// Hour gets the hour, in the range 0 to 23.
+func (t Time) Hour() int { return int(t) / 3600 }
+
+// Minute gets the minute, in the range 0 to 59.
+func (t Time) Minute() int { return int(t) % 3600 / 60 }
+
+// Second gets the second, in the range 0 to 59.
+func (t Time) Second() int { return int(t) % 60 }
+
+// String formats the time as "HH:MM:SS".
+func (t Time) String() string {
+	return fmt.Sprintf("%02d:%02d:%02d", t.Hour(), t.Minute(), t.Second())
+}
+
+func init() {
+	sconfig.RegisterType("clock.Time", sconfig.ValidateSingleValue(), handleTime)
+	sconfig.RegisterType("[]clock.Time", sconfig.ValidateValueLimit(1, 0), handleTimeSlice)
+}
+
+func handleTime(v []string) (interface{}, error) {
+	return parse(v[0])
+}
+
+func handleTimeSlice(v []string) (interface{}, error) {
+	a := make([]Time, len(v))
+	for i := range v {
+		t, err := parse(v[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = t
+	}
+	return a, nil
+}
+
+func parse(s string) (Time, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 && len(parts) != 3 {
+		return 0, fmt.Errorf("invalid time %q: must be HH:MM or HH:MM:SS", s)
+	}
+
+	var (
+		names = []string{"hour", "minute", "second"}
+		max   = []int{23, 59, 59}
+		n     [3]int
+	)
+	for i, p := range parts {
+		if len(p) != 2 {
+			return 0, fmt.Errorf("invalid time %q: must be HH:MM or HH:MM:SS", s)
+		}
+		var err error
+		n[i], err = strconv.Atoi(p)
+		if err != nil || n[i] < 0 {
+			return 0, fmt.Errorf("invalid time %q: %s is not a number", s, names[i])
+		}
+		if n[i] > max[i] {
+			return 0, fmt.Errorf("invalid time %q: %s must be between 0 and %d",
+				s, names[i], max[i])
+		}
+	}
+	return New(n[0], n[1], n[2]), nil
+}
@@ -0,0 +1,125 @@
+package clock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"zgo.at/sconfig"
+)
+
+func TestClock(t *testing.T) {
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    interface{}
+		wantErr string
+	}{
+		{handleTime, []string{"09:05"}, New(9, 5, 0), ""},
+		{handleTime, []string{"00:00"}, New(0, 0, 0), ""},
+		{handleTime, []string{"23:59:59"}, New(23, 59, 59), ""},
+		{handleTime, []string{"14:30:00"}, New(14, 30, 0), ""},
+
+		{handleTime, []string{"25:00"}, nil, `invalid time "25:00": hour must be between 0 and 23`},
+		{handleTime, []string{"12:60"}, nil, `invalid time "12:60": minute must be between 0 and 59`},
+		{handleTime, []string{"12:00:61"}, nil, `invalid time "12:00:61": second must be between 0 and 59`},
+		{handleTime, []string{"9:05"}, nil, `invalid time "9:05": must be HH:MM or HH:MM:SS`},
+		{handleTime, []string{"0905"}, nil, `invalid time "0905": must be HH:MM or HH:MM:SS`},
+		{handleTime, []string{"12:00:00:00"}, nil, `invalid time "12:00:00:00": must be HH:MM or HH:MM:SS`},
+		{handleTime, []string{"ab:00"}, nil, `invalid time "ab:00": hour is not a number`},
+		{handleTime, []string{"-1:00"}, nil, `invalid time "-1:00": hour is not a number`},
+
+		{handleTimeSlice, []string{"09:00", "17:30"}, []Time{New(9, 0, 0), New(17, 30, 0)}, ""},
+		{handleTimeSlice, []string{"09:00", "24:00"}, nil, `invalid time "24:00"`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestTime(t *testing.T) {
+	c := New(23, 59, 1)
+	if c.Hour() != 23 || c.Minute() != 59 || c.Second() != 1 {
+		t.Errorf("wrong: %d %d %d", c.Hour(), c.Minute(), c.Second())
+	}
+	if s := c.String(); s != "23:59:01" {
+		t.Errorf("wrong: %s", s)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type config struct {
+		Start Time
+		Times []Time
+	}
+	in := config{Start: New(9, 30, 0), Times: []Time{New(0, 0, 1), New(23, 59, 59)}}
+	b, err := sconfig.Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "start 09:30:00\ntimes 00:00:01 23:59:59\n"; string(b) != want {
+		t.Errorf("\nwant: %q\nout:  %q", want, b)
+	}
+
+	fp, err := ioutil.TempFile("", "sconfig_clock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.Write(b)
+	fp.Close()
+
+	var out config
+	err = sconfig.Parse(&out, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("\nwant: %#v\nout:  %#v", in, out)
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_clock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("start 09:05\nend 23:59:59\nbreaks 12:00 15:30\n")
+	fp.Close()
+
+	var c struct {
+		Start, End Time
+		Breaks     []Time
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Start.String() != "09:05:00" || c.End.String() != "23:59:59" ||
+		!reflect.DeepEqual(c.Breaks, []Time{New(12, 0, 0), New(15, 30, 0)}) {
+		t.Errorf("wrong: %v", c)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
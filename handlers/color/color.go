@@ -0,0 +1,127 @@
+// Package color contains handlers for color.RGBA from the image/color package.
+//
+// Colors can be written as hex in the #rgb, #rrggbb, or #rrggbbaa forms, or as
+// rgb(r, g, b) with decimal values from 0 to 255:
+//
+//     fg     \#ff00aa
+//     bg     rgb(10, 20, 30)
+//     border f0a
+//
+// The "#" starts a comment, so it must be escaped as "\#" or left out; an
+// unescaped "fg #ff00aa" is an error as there is no value. The alpha is 255 if it's not given. Values with an alpha are converted to the
+// alpha-premultiplied values that color.RGBA uses.
+//
+// For []color.RGBA the colors are separated by whitespace:
+//
+//     palette f00 0f0 rgb(0, 0, 255)
+package color
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+
+	"zgo.at/sconfig"
+)
+
+func init() {
+	sconfig.RegisterType("color.RGBA", validateColor, handleRGBA)
+	sconfig.RegisterType("[]color.RGBA", validateColor, handleRGBASlice)
+}
+
+var errNoColor = errors.New(`no color; "#" starts a comment, so write "\#ff00aa" or "ff00aa"`)
+
+// validateColor checks there is at least one value; the most common reason for
+// there being none is an unescaped "#".
+func validateColor(v []string) (interface{}, error) {
+	if len(v) == 0 {
+		return nil, errNoColor
+	}
+	return v, nil
+}
+
+func handleRGBA(v []string) (interface{}, error) {
+	return parse(strings.Join(v, ""))
+}
+
+func handleRGBASlice(v []string) (interface{}, error) {
+	colors := split(v)
+	a := make([]color.RGBA, len(colors))
+	for i := range colors {
+		c, err := parse(colors[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = c
+	}
+	return a, nil
+}
+
+// split the values in to colors; rgb(..) can contain whitespace.
+func split(v []string) []string {
+	var colors []string
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if strings.HasPrefix(c, "rgb(") {
+			for !strings.Contains(c, ")") && i+1 < len(v) {
+				i++
+				c += v[i]
+			}
+		}
+		colors = append(colors, c)
+	}
+	return colors
+}
+
+func parse(s string) (color.RGBA, error) {
+	if strings.HasPrefix(s, "rgb(") {
+		return parseRGB(s)
+	}
+	return parseHex(s)
+}
+
+func parseHex(s string) (color.RGBA, error) {
+	h := strings.TrimPrefix(s, "#")
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	if len(h) == 6 {
+		h += "ff"
+	}
+	if len(h) != 8 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: hex color must be 3, 6, or 8 characters", s)
+	}
+
+	n, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: not a hex number", s)
+	}
+	return rgba(uint8(n>>24), uint8(n>>16), uint8(n>>8), uint8(n)), nil
+}
+
+func parseRGB(s string) (color.RGBA, error) {
+	if !strings.HasSuffix(s, ")") {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: no closing )", s)
+	}
+	parts := strings.Split(s[4:len(s)-1], ",")
+	if len(parts) != 3 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q: must have 3 components", s)
+	}
+
+	var c [3]uint8
+	for i, p := range parts {
+		n, err := strconv.ParseUint(strings.TrimSpace(p), 10, 8)
+		if err != nil {
+			return color.RGBA{}, fmt.Errorf("invalid color %q: component %q is not a number from 0 to 255", s, p)
+		}
+		c[i] = uint8(n)
+	}
+	return rgba(c[0], c[1], c[2], 255), nil
+}
+
+// rgba converts non-premultiplied values to a color.RGBA.
+func rgba(r, g, b, a uint8) color.RGBA {
+	return color.RGBAModel.Convert(color.NRGBA{R: r, G: g, B: b, A: a}).(color.RGBA)
+}
@@ -0,0 +1,109 @@
+package color
+
+import (
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"zgo.at/sconfig"
+)
+
+func TestRGBA(t *testing.T) {
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    interface{}
+		wantErr string
+	}{
+		{handleRGBA, []string{"#ff00aa"}, color.RGBA{0xff, 0x00, 0xaa, 0xff}, ""},
+		{handleRGBA, []string{"ff00aa"}, color.RGBA{0xff, 0x00, 0xaa, 0xff}, ""},
+		{handleRGBA, []string{"#F0a"}, color.RGBA{0xff, 0x00, 0xaa, 0xff}, ""},
+		{handleRGBA, []string{"#ffffff80"}, color.RGBA{0x80, 0x80, 0x80, 0x80}, ""},
+		{handleRGBA, []string{"#00000000"}, color.RGBA{}, ""},
+		{handleRGBA, []string{"rgb(10,20,30)"}, color.RGBA{10, 20, 30, 255}, ""},
+		{handleRGBA, []string{"rgb(10,", "20,", "30)"}, color.RGBA{10, 20, 30, 255}, ""},
+
+		{handleRGBA, []string{"#ff00a"}, nil, `invalid color "#ff00a": hex color must be 3, 6, or 8 characters`},
+		{handleRGBA, []string{"#ff00ag"}, nil, `invalid color "#ff00ag": not a hex number`},
+		{handleRGBA, []string{"+f0"}, nil, `invalid color "+f0": not a hex number`},
+		{handleRGBA, []string{"rgb(10,20,256)"}, nil, `component "256" is not a number from 0 to 255`},
+		{handleRGBA, []string{"rgb(10,-1,30)"}, nil, `component "-1" is not a number from 0 to 255`},
+		{handleRGBA, []string{"rgb(10,20)"}, nil, `invalid color "rgb(10,20)": must have 3 components`},
+		{handleRGBA, []string{"rgb(10,20,30"}, nil, `invalid color "rgb(10,20,30": no closing )`},
+
+		{handleRGBASlice, []string{"f00", "rgb(0,", "255,", "0)", "#0000ff"},
+			[]color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}, ""},
+		{handleRGBASlice, []string{"f00", "rgb(0,", "255"}, nil, `no closing )`},
+		{handleRGBASlice, []string{"f00", "x"}, nil, `invalid color "x"`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	// Same as the package docs.
+	fp.WriteString("fg     \\#ff00aa\nbg     rgb(10, 20, 30)\nborder f0a\n" +
+		"palette f00 0f0 rgb(0, 0, 255)\n")
+	fp.Close()
+
+	var c struct {
+		Fg      color.RGBA
+		Bg      color.RGBA
+		Border  color.RGBA
+		Palette []color.RGBA
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []color.RGBA{{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255}}
+	if c.Fg != (color.RGBA{0xff, 0x00, 0xaa, 0xff}) || c.Bg != (color.RGBA{10, 20, 30, 255}) ||
+		c.Border != (color.RGBA{0xff, 0x00, 0xaa, 0xff}) || !reflect.DeepEqual(c.Palette, want) {
+		t.Errorf("wrong: %v", c)
+	}
+}
+
+func TestParseUnescaped(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("fg #ff00aa\n")
+	fp.Close()
+
+	var c struct{ Fg color.RGBA }
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if !errorContains(err, `error parsing fg: no color; "#" starts a comment`) {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
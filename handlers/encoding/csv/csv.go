@@ -0,0 +1,51 @@
+// Package csv contains handlers for parsing values with the encoding/csv
+// package.
+//
+// It implements the Record type, which is a []string that's split with CSV
+// semantics rather than on whitespace:
+//
+//     row "a,b",c,"d""e"
+//
+// Will be parsed as []string{"a,b", "c", `d"e`}. Note this uses CSV quoting
+// rules and not shell quoting rules: the only escape is a doubled quote inside
+// a quoted field, and single quotes have no special meaning.
+//
+// sconfig collapses whitespace before the value is passed to the CSV reader,
+// so "a,  b" is read as "a", " b". Use "\ " to preserve whitespace.
+package csv
+
+import (
+	"encoding/csv"
+	"strings"
+
+	"zgo.at/sconfig"
+)
+
+// Record is a CSV record.
+//
+// Like a []string, multiple lines for the same key are appended to the
+// record; use []Record to get one record per line.
+type Record []string
+
+func init() {
+	sconfig.RegisterType("csv.Record", sconfig.ValidateValueLimit(1, 0), handleRecord)
+	sconfig.RegisterType("[]csv.Record", sconfig.ValidateValueLimit(1, 0), handleRecordSlice)
+}
+
+func handleRecord(v []string) (interface{}, error) {
+	r := csv.NewReader(strings.NewReader(strings.Join(v, " ")))
+	r.FieldsPerRecord = -1
+	rec, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	return Record(rec), nil
+}
+
+func handleRecordSlice(v []string) (interface{}, error) {
+	rec, err := handleRecord(v)
+	if err != nil {
+		return nil, err
+	}
+	return []Record{rec.(Record)}, nil
+}
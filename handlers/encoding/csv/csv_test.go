@@ -0,0 +1,84 @@
+package csv
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"zgo.at/sconfig"
+)
+
+func TestCSV(t *testing.T) {
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    interface{}
+		wantErr string
+	}{
+		{handleRecord, []string{"a"}, Record{"a"}, ""},
+		{handleRecord, []string{"a,b,c"}, Record{"a", "b", "c"}, ""},
+		{handleRecord, []string{`"a,b",c`}, Record{"a,b", "c"}, ""},
+		{handleRecord, []string{`"d""e"`}, Record{`d"e`}, ""},
+		{handleRecord, []string{`"a,b",c,"d""e"`}, Record{"a,b", "c", `d"e`}, ""},
+		{handleRecord, []string{`"hello`, `world",x`}, Record{"hello world", "x"}, ""},
+		{handleRecord, []string{`'a,b'`}, Record{"'a", "b'"}, ""},
+		{handleRecord, []string{`"a`}, nil, `extraneous or missing " in quoted-field`},
+
+		{handleRecordSlice, []string{`"a,b",c`}, []Record{{"a,b", "c"}}, ""},
+		{handleRecordSlice, []string{`a"b`}, nil, `bare " in non-quoted-field`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fmt.Fprintln(fp, `row "a,b",c,"d""e"`)
+	fmt.Fprintln(fp, `row x,y`)
+	fmt.Fprintln(fp, `header name,value`)
+	fp.Close()
+
+	var c struct {
+		Rows   []Record
+		Header Record
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Record{{"a,b", "c", `d"e`}, {"x", "y"}}
+	if !reflect.DeepEqual(c.Rows, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, c.Rows)
+	}
+	if !reflect.DeepEqual(c.Header, Record{"name", "value"}) {
+		t.Errorf("wrong header: %#v", c.Header)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
@@ -0,0 +1,48 @@
+// Package json contains handlers for parsing values with the encoding/json
+// package.
+//
+// It currently implements the json.Number type, which stores the number
+// exactly as it's written:
+//
+//     amount 12345678901234567890
+//
+// Will be json.Number("12345678901234567890"), without any loss of precision.
+// The value must be a valid JSON number; a leading "+", leading zeros, or
+// hexadecimal numbers are errors.
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"zgo.at/sconfig"
+)
+
+func init() {
+	sconfig.RegisterType("json.Number", sconfig.ValidateSingleValue(), handleNumber)
+	sconfig.RegisterType("[]json.Number", sconfig.ValidateValueLimit(1, 0), handleNumberSlice)
+}
+
+func handleNumber(v []string) (interface{}, error) {
+	return parseNumber(strings.Join(v, ""))
+}
+
+func handleNumberSlice(v []string) (interface{}, error) {
+	a := make([]json.Number, len(v))
+	for i := range v {
+		n, err := parseNumber(v[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = n
+	}
+	return a, nil
+}
+
+func parseNumber(s string) (json.Number, error) {
+	if s == "" || (s[0] != '-' && (s[0] < '0' || s[0] > '9')) || !json.Valid([]byte(s)) {
+		return "", fmt.Errorf("not a valid JSON number: %q", s)
+	}
+	return json.Number(s), nil
+}
@@ -0,0 +1,89 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"zgo.at/sconfig"
+)
+
+func TestNumber(t *testing.T) {
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    interface{}
+		wantErr string
+	}{
+		{handleNumber, []string{"42"}, json.Number("42"), ""},
+		{handleNumber, []string{"12345678901234567890"}, json.Number("12345678901234567890"), ""},
+		{handleNumber, []string{"-0.10000000000000000001"}, json.Number("-0.10000000000000000001"), ""},
+		{handleNumber, []string{"1.50"}, json.Number("1.50"), ""},
+		{handleNumber, []string{"6.02E+23"}, json.Number("6.02E+23"), ""},
+
+		{handleNumber, []string{"x"}, nil, `not a valid JSON number: "x"`},
+		{handleNumber, []string{"+1"}, nil, `not a valid JSON number: "+1"`},
+		{handleNumber, []string{"01"}, nil, `not a valid JSON number: "01"`},
+		{handleNumber, []string{"0x10"}, nil, `not a valid JSON number: "0x10"`},
+		{handleNumber, []string{"1."}, nil, `not a valid JSON number: "1."`},
+		{handleNumber, []string{"-"}, nil, `not a valid JSON number: "-"`},
+		{handleNumber, []string{`"1"`}, nil, `not a valid JSON number: "\"1\""`},
+		{handleNumber, []string{"[1]"}, nil, `not a valid JSON number: "[1]"`},
+
+		{handleNumberSlice, []string{"1", "2.5", "99999999999999999999"},
+			[]json.Number{"1", "2.5", "99999999999999999999"}, ""},
+		{handleNumberSlice, []string{"1", "true"}, nil, `not a valid JSON number: "true"`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fmt.Fprintln(fp, "amount 12345678901234567890")
+	fmt.Fprintln(fp, "prices 0.1 19.99")
+	fp.Close()
+
+	var c struct {
+		Amount json.Number
+		Prices []json.Number
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Amount != "12345678901234567890" {
+		t.Errorf("wrong amount: %q", c.Amount)
+	}
+	if !reflect.DeepEqual(c.Prices, []json.Number{"0.1", "19.99"}) {
+		t.Errorf("wrong prices: %#v", c.Prices)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
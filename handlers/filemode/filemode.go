@@ -0,0 +1,87 @@
+// Package filemode contains handlers for os.FileMode.
+//
+// The mode is written as an octal number, as with chmod:
+//
+//     umask    0022
+//     dir-perm 0755
+//     suid     4755
+//
+// The leading 0 is optional. Only the permission bits and the setuid (4000),
+// setgid (2000), and sticky (1000) bits can be set; these are converted to
+// os.ModeSetuid, os.ModeSetgid, and os.ModeSticky.
+//
+// sconfig.Marshal() writes the mode as a 4-digit octal number.
+package filemode
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+
+	"zgo.at/sconfig"
+)
+
+func init() {
+	// os.FileMode is an alias for fs.FileMode since Go 1.16, so get the name
+	// from reflect rather than hard-coding it.
+	name := reflect.TypeOf(os.FileMode(0)).String()
+	sconfig.RegisterType(name, sconfig.ValidateSingleValue(), handleMode)
+	sconfig.RegisterType("[]"+name, sconfig.ValidateValueLimit(1, 0), handleModeSlice)
+	sconfig.RegisterMarshaler(name, marshalMode)
+}
+
+// marshalMode writes the mode as a 4-digit octal number; os.FileMode.String()
+// can't be read back.
+func marshalMode(v interface{}) (string, error) {
+	m := v.(os.FileMode)
+	n := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		n |= 04000
+	}
+	if m&os.ModeSetgid != 0 {
+		n |= 02000
+	}
+	if m&os.ModeSticky != 0 {
+		n |= 01000
+	}
+	return fmt.Sprintf("%04o", n), nil
+}
+
+func handleMode(v []string) (interface{}, error) {
+	return parse(v[0])
+}
+
+func handleModeSlice(v []string) (interface{}, error) {
+	a := make([]os.FileMode, len(v))
+	for i := range v {
+		m, err := parse(v[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = m
+	}
+	return a, nil
+}
+
+func parse(s string) (os.FileMode, error) {
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid file mode %q: not an octal number", s)
+	}
+	if n > 07777 {
+		return 0, fmt.Errorf("invalid file mode %q: larger than 7777", s)
+	}
+
+	m := os.FileMode(n & 0777)
+	if n&04000 != 0 {
+		m |= os.ModeSetuid
+	}
+	if n&02000 != 0 {
+		m |= os.ModeSetgid
+	}
+	if n&01000 != 0 {
+		m |= os.ModeSticky
+	}
+	return m, nil
+}
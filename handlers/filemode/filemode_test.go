@@ -0,0 +1,113 @@
+package filemode
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"zgo.at/sconfig"
+)
+
+func TestMode(t *testing.T) {
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    interface{}
+		wantErr string
+	}{
+		{handleMode, []string{"0755"}, os.FileMode(0755), ""},
+		{handleMode, []string{"644"}, os.FileMode(0644), ""},
+		{handleMode, []string{"0022"}, os.FileMode(0022), ""},
+		{handleMode, []string{"0"}, os.FileMode(0), ""},
+		{handleMode, []string{"4755"}, os.ModeSetuid | 0755, ""},
+		{handleMode, []string{"3777"}, os.ModeSetgid | os.ModeSticky | 0777, ""},
+
+		{handleMode, []string{"0789"}, nil, `invalid file mode "0789": not an octal number`},
+		{handleMode, []string{"rwx"}, nil, `invalid file mode "rwx": not an octal number`},
+		{handleMode, []string{"-0755"}, nil, `invalid file mode "-0755": not an octal number`},
+		{handleMode, []string{"10000"}, nil, `invalid file mode "10000": larger than 7777`},
+
+		{handleModeSlice, []string{"0644", "0600"}, []os.FileMode{0644, 0600}, ""},
+		{handleModeSlice, []string{"0644", "8"}, nil, `invalid file mode "8"`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_filemode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("umask 0022\ndir-perm 0755\nmodes 0644 0600\n")
+	fp.Close()
+
+	var c struct {
+		Umask   os.FileMode
+		DirPerm os.FileMode
+		Modes   []os.FileMode
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Umask != 0022 || c.DirPerm != 0755 || !reflect.DeepEqual(c.Modes, []os.FileMode{0644, 0600}) {
+		t.Errorf("wrong: %v", c)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type config struct {
+		Perm  os.FileMode
+		Modes []os.FileMode
+	}
+	in := config{Perm: 0644, Modes: []os.FileMode{0755, os.ModeSetuid | os.ModeSticky | 0700}}
+	b, err := sconfig.Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "perm 0644\nmodes 0755 5700\n"; string(b) != want {
+		t.Errorf("\nwant: %q\nout:  %q", want, b)
+	}
+
+	fp, err := ioutil.TempFile("", "sconfig_filemode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.Write(b)
+	fp.Close()
+
+	var out config
+	err = sconfig.Parse(&out, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("\nwant: %#v\nout:  %#v", in, out)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
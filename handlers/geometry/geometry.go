@@ -0,0 +1,114 @@
+// Package geometry contains handlers for image dimensions and X11-style
+// geometry strings.
+//
+// It implements the Geometry type, which is written as:
+//
+//     WxH            Width and height; e.g. "1920x1080".
+//     WxH+X+Y        Width and height with an offset; e.g. "640x480+10+20".
+//
+// The width and height must be positive integers. The offsets are integers
+// which always start with a "+" or "-", so "640x480-10+20" has an X offset of
+// -10.
+package geometry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"zgo.at/sconfig"
+)
+
+// Geometry is a size with an optional offset.
+type Geometry struct {
+	Width, Height int
+	X, Y          int
+	HasOffset     bool // The geometry has an X and Y offset.
+}
+
+// String formats the geometry as "WxH" or "WxH+X+Y".
+func (g Geometry) String() string {
+	if !g.HasOffset {
+		return fmt.Sprintf("%dx%d", g.Width, g.Height)
+	}
+	return fmt.Sprintf("%dx%d%+d%+d", g.Width, g.Height, g.X, g.Y)
+}
+
+func init() {
+	sconfig.RegisterType("geometry.Geometry", sconfig.ValidateSingleValue(), handleGeometry)
+	sconfig.RegisterType("[]geometry.Geometry", sconfig.ValidateValueLimit(1, 0), handleGeometrySlice)
+}
+
+func handleGeometry(v []string) (interface{}, error) {
+	return parse(v[0])
+}
+
+func handleGeometrySlice(v []string) (interface{}, error) {
+	a := make([]Geometry, len(v))
+	for i := range v {
+		g, err := parse(v[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = g
+	}
+	return a, nil
+}
+
+func parse(s string) (Geometry, error) {
+	var g Geometry
+	errInvalid := fmt.Errorf("invalid geometry %q: must be WxH or WxH+X+Y", s)
+
+	x := strings.IndexByte(s, 'x')
+	if x == -1 {
+		return g, errInvalid
+	}
+	size, offset := s[x+1:], ""
+	if i := strings.IndexAny(size, "+-"); i > -1 {
+		size, offset = size[:i], size[i:]
+	}
+
+	var err error
+	g.Width, err = dimension(s[:x])
+	if err != nil {
+		return g, fmt.Errorf("invalid geometry %q: width %v", s, err)
+	}
+	g.Height, err = dimension(size)
+	if err != nil {
+		return g, fmt.Errorf("invalid geometry %q: height %v", s, err)
+	}
+	if offset == "" {
+		return g, nil
+	}
+
+	i := strings.IndexAny(offset[1:], "+-")
+	if i == -1 {
+		return g, errInvalid
+	}
+	g.X, err = strconv.Atoi(offset[:i+1])
+	if err != nil {
+		return g, errInvalid
+	}
+	g.Y, err = strconv.Atoi(offset[i+1:])
+	if err != nil {
+		return g, errInvalid
+	}
+	g.HasOffset = true
+	return g, nil
+}
+
+func dimension(s string) (int, error) {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%q is not a number", s)
+		}
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("must be larger than 0")
+	}
+	return n, nil
+}
@@ -0,0 +1,141 @@
+package geometry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"zgo.at/sconfig"
+)
+
+func TestGeometry(t *testing.T) {
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    interface{}
+		wantErr string
+	}{
+		{handleGeometry, []string{"1920x1080"}, Geometry{Width: 1920, Height: 1080}, ""},
+		{handleGeometry, []string{"640x480+10+20"},
+			Geometry{Width: 640, Height: 480, X: 10, Y: 20, HasOffset: true}, ""},
+		{handleGeometry, []string{"640x480-10+0"},
+			Geometry{Width: 640, Height: 480, X: -10, Y: 0, HasOffset: true}, ""},
+		{handleGeometry, []string{"1x1-0-5"},
+			Geometry{Width: 1, Height: 1, X: 0, Y: -5, HasOffset: true}, ""},
+
+		{handleGeometry, []string{"1920"}, nil, `invalid geometry "1920": must be WxH or WxH+X+Y`},
+		{handleGeometry, []string{"x1080"}, nil, `invalid geometry "x1080": width "" is not a number`},
+		{handleGeometry, []string{"1920x"}, nil, `invalid geometry "1920x": height "" is not a number`},
+		{handleGeometry, []string{"axb"}, nil, `invalid geometry "axb": width "a" is not a number`},
+		{handleGeometry, []string{"-5x10"}, nil, `invalid geometry "-5x10": width "-5" is not a number`},
+		{handleGeometry, []string{"0x10"}, nil, `invalid geometry "0x10": width must be larger than 0`},
+		{handleGeometry, []string{"10x10+5"}, nil, `invalid geometry "10x10+5": must be WxH or WxH+X+Y`},
+		{handleGeometry, []string{"10x10+5+"}, nil, `invalid geometry "10x10+5+": must be WxH or WxH+X+Y`},
+		{handleGeometry, []string{"10x10+-5+5"}, nil, `invalid geometry "10x10+-5+5": must be WxH or WxH+X+Y`},
+		{handleGeometry, []string{"10x10+5+5+5"}, nil, `invalid geometry "10x10+5+5+5": must be WxH or WxH+X+Y`},
+		{handleGeometry, []string{"10x10+a+5"}, nil, `invalid geometry "10x10+a+5": must be WxH or WxH+X+Y`},
+
+		{handleGeometrySlice, []string{"800x600", "1024x768+0+0"}, []Geometry{
+			{Width: 800, Height: 600},
+			{Width: 1024, Height: 768, HasOffset: true},
+		}, ""},
+		{handleGeometrySlice, []string{"800x600", "1024"}, nil, `invalid geometry "1024"`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	for _, s := range []string{"1920x1080", "640x480+10+20", "640x480-10-0"} {
+		g, err := parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := strings.Replace(s, "-0", "+0", 1)
+		if g.String() != want {
+			t.Errorf("want %q, got %q", want, g.String())
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_geometry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fmt.Fprintln(fp, "size 1920x1080")
+	fmt.Fprintln(fp, "windows 640x480+10+20 800x600")
+	fp.Close()
+
+	var c struct {
+		Size    Geometry
+		Windows []Geometry
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size.String() != "1920x1080" || len(c.Windows) != 2 ||
+		c.Windows[0].String() != "640x480+10+20" || c.Windows[1].String() != "800x600" {
+		t.Errorf("wrong: %v", c)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type config struct {
+		Size    Geometry
+		Windows []Geometry
+	}
+	in := config{
+		Size:    Geometry{Width: 10, Height: 20},
+		Windows: []Geometry{{Width: 640, Height: 480, X: -10, Y: 20, HasOffset: true}},
+	}
+	b, err := sconfig.Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "size 10x20\nwindows 640x480-10+20\n"; string(b) != want {
+		t.Errorf("\nwant: %q\nout:  %q", want, b)
+	}
+
+	fp, err := ioutil.TempFile("", "sconfig_geometry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.Write(b)
+	fp.Close()
+
+	var out config
+	err = sconfig.Parse(&out, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("\nwant: %#v\nout:  %#v", in, out)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
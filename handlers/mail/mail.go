@@ -0,0 +1,69 @@
+// Package mail contains handlers for parsing values with the net/mail package.
+//
+// It currently implements the *mail.Address type, which can be written as a
+// bare address or with a display name:
+//
+//     notify "Alice Smith" <alice@example.com> bob@example.com
+//     from   Alice <alice@example.com>
+//
+// Values are split on whitespace, and the words of a display name are joined
+// again with a single space, so a display name with single spaces doesn't need
+// escaping. Whitespace is collapsed as in any other value, so escape the extra
+// spaces with "\ " to keep them:
+//
+//     from "Alice\ \ Smith" <alice@example.com>
+package mail
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"zgo.at/sconfig"
+)
+
+func init() {
+	sconfig.RegisterType("*mail.Address", sconfig.ValidateValueLimit(1, 0), handleAddress)
+	sconfig.RegisterType("[]*mail.Address", sconfig.ValidateValueLimit(1, 0), handleAddressSlice)
+}
+
+func handleAddress(v []string) (interface{}, error) {
+	return parse(strings.Join(v, " "))
+}
+
+func handleAddressSlice(v []string) (interface{}, error) {
+	var (
+		a   []*mail.Address
+		cur []string
+	)
+	for _, t := range v {
+		cur = append(cur, t)
+
+		// A bare address, or the end of an address with a display name.
+		named := strings.ContainsAny(cur[0], `<"`) || len(cur) > 1
+		if (named && strings.HasSuffix(t, ">")) || (!named && strings.Contains(t, "@")) {
+			addr, err := parse(strings.Join(cur, " "))
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, addr)
+			cur = nil
+		}
+	}
+	if len(cur) > 0 {
+		_, err := parse(strings.Join(cur, " "))
+		if err == nil {
+			err = fmt.Errorf("invalid address %q", strings.Join(cur, " "))
+		}
+		return nil, err
+	}
+	return a, nil
+}
+
+func parse(s string) (*mail.Address, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %v", s, err)
+	}
+	return addr, nil
+}
@@ -0,0 +1,125 @@
+package mail
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"zgo.at/sconfig"
+)
+
+func TestAddress(t *testing.T) {
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    interface{}
+		wantErr string
+	}{
+		{handleAddress, []string{"bob@example.com"}, &mail.Address{Address: "bob@example.com"}, ""},
+		{handleAddress, []string{`"Alice`, `Smith"`, "<alice@example.com>"},
+			&mail.Address{Name: "Alice Smith", Address: "alice@example.com"}, ""},
+		{handleAddress, []string{"Alice", "<alice@example.com>"},
+			&mail.Address{Name: "Alice", Address: "alice@example.com"}, ""},
+		{handleAddress, []string{"bob"}, nil, `invalid address "bob": mail: missing '@' or angle-addr`},
+
+		{
+			handleAddressSlice,
+			[]string{`"Alice`, `Smith"`, "<alice@example.com>", "bob@example.com", "Carol", "<carol@example.com>", "<dave@example.com>"},
+			[]*mail.Address{
+				{Name: "Alice Smith", Address: "alice@example.com"},
+				{Address: "bob@example.com"},
+				{Name: "Carol", Address: "carol@example.com"},
+				{Address: "dave@example.com"},
+			},
+			"",
+		},
+		{handleAddressSlice, []string{"bob@example.com", "alice@@example.com"}, nil, `invalid address "alice@@example.com"`},
+		{handleAddressSlice, []string{"bob@example.com", "Alice"}, nil, `invalid address "Alice"`},
+		{handleAddressSlice, []string{"Alice", "<alice@example.com"}, nil, `invalid address "Alice <alice@example.com"`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("notify \"Alice Smith\" <alice@example.com> bob@example.com\nfrom Alice <alice@example.com>\n")
+	fp.Close()
+
+	var c struct {
+		Notify []*mail.Address
+		From   *mail.Address
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*mail.Address{
+		{Name: "Alice Smith", Address: "alice@example.com"},
+		{Address: "bob@example.com"},
+	}
+	if !reflect.DeepEqual(c.Notify, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, c.Notify)
+	}
+	if c.From.Name != "Alice" || c.From.Address != "alice@example.com" {
+		t.Errorf("wrong: %#v", c.From)
+	}
+}
+
+func TestParseEscaped(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("from \"Alice\\ \\ Smith\" <alice@example.com>\n" +
+		"notify \"Bob  Jones\" <bob@example.com> \"Carol\\ \\ Doe\" <carol@example.com>\n")
+	fp.Close()
+
+	var c struct {
+		From   *mail.Address
+		Notify []*mail.Address
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.From.Name != "Alice  Smith" {
+		t.Errorf("wrong: %q", c.From.Name)
+	}
+	want := []*mail.Address{
+		{Name: "Bob Jones", Address: "bob@example.com"},
+		{Name: "Carol  Doe", Address: "carol@example.com"},
+	}
+	if !reflect.DeepEqual(c.Notify, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, c.Notify)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
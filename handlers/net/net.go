@@ -1,11 +1,24 @@
 // Package net contains handlers for parsing values with the net package.
 //
-// It currently implements the net.IP type.
+// It currently implements the net.IP, net.IPMask, net.IPNet, and
+// net.HardwareAddr types.
+//
+// A net.IPNet is written in CIDR notation, such as "10.0.0.0/8" or
+// "2001:db8::/32"; the mask is required. Fields can also be a *net.IPNet.
+//
+// A net.IPMask can be written as:
+//
+//     mask 255.255.255.0        IPv4 dotted-decimal.
+//     mask /24                  IPv4 prefix length (0 to 32).
+//     mask ffff:ffff:ffff::     IPv6 address notation.
+//
+// It's an error if the mask isn't contiguous (e.g. "255.0.255.0").
 package net
 
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 
 	"zgo.at/sconfig"
@@ -14,6 +27,12 @@ import (
 func init() {
 	sconfig.RegisterType("net.IP", sconfig.ValidateSingleValue(), handleIP)
 	sconfig.RegisterType("[]net.IP", sconfig.ValidateValueLimit(1, 0), handleIPSlice)
+	sconfig.RegisterType("net.IPMask", sconfig.ValidateSingleValue(), handleIPMask)
+	sconfig.RegisterType("[]net.IPMask", sconfig.ValidateValueLimit(1, 0), handleIPMaskSlice)
+	sconfig.RegisterType("net.IPNet", sconfig.ValidateSingleValue(), handleIPNet)
+	sconfig.RegisterType("[]net.IPNet", sconfig.ValidateValueLimit(1, 0), handleIPNetSlice)
+	sconfig.RegisterType("net.HardwareAddr", sconfig.ValidateSingleValue(), handleHardwareAddr)
+	sconfig.RegisterType("[]net.HardwareAddr", sconfig.ValidateValueLimit(1, 0), handleHardwareAddrSlice)
 }
 
 // handleIP parses an IPv4 or IPv6 address
@@ -39,3 +58,83 @@ func handleIPSlice(v []string) (interface{}, error) {
 	}
 	return a, nil
 }
+
+// handleIPMask parses a dotted-decimal, IPv6, or "/n" prefix length mask.
+func handleIPMask(v []string) (interface{}, error) {
+	s := strings.Join(v, "")
+	if strings.HasPrefix(s, "/") {
+		n, err := strconv.Atoi(s[1:])
+		if err != nil || n < 0 || n > 32 {
+			return nil, fmt.Errorf("not a valid IPv4 prefix length: %v", s)
+		}
+		return net.CIDRMask(n, 32), nil
+	}
+
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, fmt.Errorf("not a valid IP mask: %v", s)
+	}
+	mask := net.IPMask(ip)
+	if ip4 := ip.To4(); ip4 != nil && strings.Contains(s, ".") {
+		mask = net.IPMask(ip4)
+	}
+	if _, bits := mask.Size(); bits == 0 {
+		return nil, fmt.Errorf("not a contiguous IP mask: %v", s)
+	}
+	return mask, nil
+}
+
+func handleIPMaskSlice(v []string) (interface{}, error) {
+	a := make([]net.IPMask, len(v))
+	for i := range v {
+		m, err := handleIPMask([]string{v[i]})
+		if err != nil {
+			return nil, err
+		}
+		a[i] = m.(net.IPMask)
+	}
+	return a, nil
+}
+
+// handleIPNet parses a CIDR range.
+func handleIPNet(v []string) (interface{}, error) {
+	_, n, err := net.ParseCIDR(v[0])
+	if err != nil {
+		return nil, fmt.Errorf("not a valid CIDR range: %v", v[0])
+	}
+	return *n, nil
+}
+
+func handleIPNetSlice(v []string) (interface{}, error) {
+	a := make([]net.IPNet, len(v))
+	for i := range v {
+		n, err := handleIPNet([]string{v[i]})
+		if err != nil {
+			return nil, err
+		}
+		a[i] = n.(net.IPNet)
+	}
+	return a, nil
+}
+
+// handleHardwareAddr parses a MAC address in any of the formats that
+// net.ParseMAC() accepts.
+func handleHardwareAddr(v []string) (interface{}, error) {
+	a, err := net.ParseMAC(v[0])
+	if err != nil {
+		return nil, fmt.Errorf("not a valid MAC address: %v", v[0])
+	}
+	return a, nil
+}
+
+func handleHardwareAddrSlice(v []string) (interface{}, error) {
+	a := make([]net.HardwareAddr, len(v))
+	for i := range v {
+		m, err := handleHardwareAddr([]string{v[i]})
+		if err != nil {
+			return nil, err
+		}
+		a[i] = m.(net.HardwareAddr)
+	}
+	return a, nil
+}
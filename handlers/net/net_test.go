@@ -2,7 +2,9 @@ package net
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
+	"os"
 	"reflect"
 	"strings"
 	"testing"
@@ -38,6 +40,46 @@ func TestNet(t *testing.T) {
 			handleIPSlice, []string{"127.0.0.1", "127.0.0.1X"},
 			nil, "not a valid IP address: 127.0.0.1X",
 		},
+
+		{handleIPMask, []string{"255.255.255.0"}, net.IPv4Mask(255, 255, 255, 0), ""},
+		{handleIPMask, []string{"0.0.0.0"}, net.IPv4Mask(0, 0, 0, 0), ""},
+		{handleIPMask, []string{"/24"}, net.CIDRMask(24, 32), ""},
+		{handleIPMask, []string{"/0"}, net.CIDRMask(0, 32), ""},
+		{handleIPMask, []string{"/32"}, net.CIDRMask(32, 32), ""},
+		{handleIPMask, []string{"ffff:ffff:ffff:ffff::"}, net.CIDRMask(64, 128), ""},
+		{handleIPMask, []string{"255.0.255.0"}, nil, "not a contiguous IP mask: 255.0.255.0"},
+		{handleIPMask, []string{"255.255.256.0"}, nil, "not a valid IP mask: 255.255.256.0"},
+		{handleIPMask, []string{"/33"}, nil, "not a valid IPv4 prefix length: /33"},
+		{handleIPMask, []string{"/x"}, nil, "not a valid IPv4 prefix length: /x"},
+		{
+			handleIPMaskSlice, []string{"255.0.0.0", "/16"},
+			[]net.IPMask{net.CIDRMask(8, 32), net.CIDRMask(16, 32)},
+			"",
+		},
+		{handleIPMaskSlice, []string{"/8", "0.255.0.0"}, nil, "not a contiguous IP mask: 0.255.0.0"},
+
+		{handleIPNet, []string{"10.0.0.0/8"}, cidr("10.0.0.0/8"), ""},
+		{handleIPNet, []string{"10.1.2.3/8"}, cidr("10.0.0.0/8"), ""},
+		{handleIPNet, []string{"2001:db8::/32"}, cidr("2001:db8::/32"), ""},
+		{handleIPNet, []string{"10.0.0.1"}, nil, "not a valid CIDR range: 10.0.0.1"},
+		{handleIPNet, []string{"10.0.0.0/33"}, nil, "not a valid CIDR range: 10.0.0.0/33"},
+		{
+			handleIPNetSlice, []string{"10.0.0.0/8", "fd00::/8"},
+			[]net.IPNet{cidr("10.0.0.0/8"), cidr("fd00::/8")},
+			"",
+		},
+		{handleIPNetSlice, []string{"10.0.0.0/8", "::1"}, nil, "not a valid CIDR range: ::1"},
+
+		{handleHardwareAddr, []string{"00:11:22:33:44:55"}, net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}, ""},
+		{handleHardwareAddr, []string{"00-11-22-AA-BB-CC"}, net.HardwareAddr{0, 0x11, 0x22, 0xaa, 0xbb, 0xcc}, ""},
+		{handleHardwareAddr, []string{"0011.2233.4455"}, net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55}, ""},
+		{handleHardwareAddr, []string{"00:11:22:33:44"}, nil, "not a valid MAC address: 00:11:22:33:44"},
+		{
+			handleHardwareAddrSlice, []string{"00:11:22:33:44:55", "66:77:88:99:aa:bb"},
+			[]net.HardwareAddr{{0, 0x11, 0x22, 0x33, 0x44, 0x55}, {0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb}},
+			"",
+		},
+		{handleHardwareAddrSlice, []string{"00:11:22:33:44:55", "zz:11:22:33:44:55"}, nil, "not a valid MAC address: zz:11:22:33:44:55"},
 	}
 
 	for i, tc := range cases {
@@ -54,6 +96,39 @@ func TestNet(t *testing.T) {
 	}
 }
 
+func cidr(s string) net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return *n
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("network 10.0.0.0/8\nallow 192.168.0.0/16 ::1/128\npointer 2001:db8::/32\n")
+	fp.Close()
+
+	var c struct {
+		Network net.IPNet
+		Allow   []net.IPNet
+		Pointer *net.IPNet
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Network.String() != "10.0.0.0/8" || len(c.Allow) != 2 ||
+		c.Allow[0].String() != "192.168.0.0/16" || c.Allow[1].String() != "::1/128" ||
+		c.Pointer == nil || c.Pointer.String() != "2001:db8::/32" {
+		t.Errorf("wrong: %v", c)
+	}
+}
+
 func errorContains(out error, want string) bool {
 	if out == nil {
 		return want == ""
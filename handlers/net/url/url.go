@@ -12,13 +12,32 @@ import (
 	"zgo.at/sconfig"
 )
 
+// DefaultScheme is added to URLs which don't have a scheme, so that
+// "example.com:8080" becomes "http://example.com:8080" and the Host and Port
+// are set (rather than everything ending up in the Path or Opaque).
+//
+// The default is an empty string, which disables this.
+//
+// Figuring out if there's a scheme is a heuristic, as "host:port" and
+// "scheme:opaque" look identical to the url package: "localhost:8080" is parsed
+// as the scheme "localhost". Values are treated as having a scheme if:
+//
+// - they contain "://", or
+// - they start with a "/" (a path), or
+// - url.Parse() finds a scheme, and what comes after the ":" doesn't start with
+//   a digit, so "mailto:me@example.com" is left alone.
+//
+// This means that a hostname with a non-numeric port ("example.com:http") gets
+// treated as a scheme and is not modified.
+var DefaultScheme = ""
+
 func init() {
 	sconfig.RegisterType("*url.URL", sconfig.ValidateSingleValue(), handleURL)
 	sconfig.RegisterType("[]*url.URL", sconfig.ValidateValueLimit(1, 0), handleURLSlice)
 }
 
 func handleURL(v []string) (interface{}, error) {
-	u, err := url.Parse(strings.Join(v, ""))
+	u, err := parseURL(strings.Join(v, ""))
 	if err != nil {
 		return nil, err
 	}
@@ -28,7 +47,7 @@ func handleURL(v []string) (interface{}, error) {
 func handleURLSlice(v []string) (interface{}, error) {
 	a := make([]*url.URL, len(v))
 	for i := range v {
-		u, err := url.Parse(v[i])
+		u, err := parseURL(v[i])
 		if err != nil {
 			return nil, err
 		}
@@ -36,3 +55,21 @@ func handleURLSlice(v []string) (interface{}, error) {
 	}
 	return a, nil
 }
+
+func parseURL(v string) (*url.URL, error) {
+	if DefaultScheme != "" && !hasScheme(v) {
+		v = strings.TrimSuffix(DefaultScheme, "://") + "://" + v
+	}
+	return url.Parse(v)
+}
+
+func hasScheme(v string) bool {
+	if strings.Contains(v, "://") || strings.HasPrefix(v, "/") {
+		return true
+	}
+	u, err := url.Parse(v)
+	if err != nil || u.Scheme == "" {
+		return false
+	}
+	return u.Opaque == "" || u.Opaque[0] < '0' || u.Opaque[0] > '9'
+}
@@ -44,6 +44,67 @@ func TestURL(t *testing.T) {
 	}
 }
 
+func TestDefaultScheme(t *testing.T) {
+	DefaultScheme = "http"
+	defer func() { DefaultScheme = "" }()
+
+	cases := []struct {
+		in      string
+		want    *url.URL
+		wantErr string
+	}{
+		// No scheme.
+		{"example.com", &url.URL{Scheme: "http", Host: "example.com"}, ""},
+		{"example.com:8080", &url.URL{Scheme: "http", Host: "example.com:8080"}, ""},
+		{"example.com:8080/path", &url.URL{Scheme: "http", Host: "example.com:8080", Path: "/path"}, ""},
+		{"127.0.0.1:8080", &url.URL{Scheme: "http", Host: "127.0.0.1:8080"}, ""},
+		{"[::1]:8080", &url.URL{Scheme: "http", Host: "[::1]:8080"}, ""},
+
+		// Has scheme.
+		{"https://example.com", &url.URL{Scheme: "https", Host: "example.com"}, ""},
+		{"mailto:me@example.com", &url.URL{Scheme: "mailto", Opaque: "me@example.com"}, ""},
+		{"/path", &url.URL{Path: "/path"}, ""},
+
+		// Ambiguous: a non-numeric port looks like a scheme.
+		{"example.com:http", &url.URL{Scheme: "example.com", Opaque: "http"}, ""},
+
+		{"%", nil, "invalid URL escape"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			out, err := handleURL([]string{tc.in})
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.want == nil {
+				if out != nil {
+					t.Errorf("out not nil: %#v", out)
+				}
+				return
+			}
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+
+	t.Run("scheme with ://", func(t *testing.T) {
+		DefaultScheme = "https://"
+		out, err := handleURLSlice([]string{"example.com", "http://example.net"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []*url.URL{
+			{Scheme: "https", Host: "example.com"},
+			{Scheme: "http", Host: "example.net"},
+		}
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+		}
+	})
+}
+
 func errorContains(out error, want string) bool {
 	if out == nil {
 		return want == ""
@@ -0,0 +1,58 @@
+// Package template contains handlers for parsing values with the text/template
+// package.
+//
+// It implements the *template.Template type; the value is parsed as a template:
+//
+//     greeting Hello, {{.Name}}!
+//
+// A "delims" option in the struct tag can be used to set different delimiters:
+//
+//     Greeting *template.Template `sconfig:"delims=<< >>"`
+//
+// For []*template.Template every line is parsed as a new template.
+//
+// The handlers are registered with the full package path, so they're not used
+// for html/template.Template.
+package template
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"zgo.at/sconfig"
+)
+
+func init() {
+	sconfig.RegisterTagType("*text/template.Template", handleTemplate)
+	sconfig.RegisterTagType("[]*text/template.Template", handleTemplateSlice)
+}
+
+func handleTemplate(tag sconfig.Tag, v []string) (interface{}, error) {
+	if _, err := sconfig.ValidateValueLimit(1, 0)(v); err != nil {
+		return nil, err
+	}
+
+	t := template.New("")
+	if d := tag.Get("delims"); d != "" {
+		delims := strings.Fields(d)
+		if len(delims) != 2 {
+			return nil, fmt.Errorf("invalid delims %q: must be two values separated by a space", d)
+		}
+		t = t.Delims(delims[0], delims[1])
+	}
+
+	t, err := t.Parse(strings.Join(v, " "))
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func handleTemplateSlice(tag sconfig.Tag, v []string) (interface{}, error) {
+	t, err := handleTemplate(tag, v)
+	if err != nil {
+		return nil, err
+	}
+	return []*template.Template{t.(*template.Template)}, nil
+}
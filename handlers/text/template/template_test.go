@@ -0,0 +1,157 @@
+package template
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"text/template"
+
+	"zgo.at/sconfig"
+)
+
+func TestTemplate(t *testing.T) {
+	cases := []struct {
+		tag     sconfig.Tag
+		in      []string
+		want    string
+		wantErr string
+	}{
+		{sconfig.Tag{}, []string{"Hello,", "{{.}}!"}, "Hello, world!", ""},
+		{sconfig.Tag{}, []string{"<<.>>"}, "<<.>>", ""},
+		{delims("<< >>"), []string{"Hello,", "<<.>>", "{{.}}"}, "Hello, world {{.}}", ""},
+		{delims("[[ ]]"), []string{"[[.]]"}, "world", ""},
+
+		{sconfig.Tag{}, []string{"{{.}"}, "", `template: :1: bad character`},
+		{sconfig.Tag{}, []string{"{{nope}}"}, "", `function "nope" not defined`},
+		{sconfig.Tag{}, []string{}, "", "must have more than 1 values (has: 0)"},
+		{delims("<<"), []string{"<<.>>"}, "", `invalid delims "<<"`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := handleTemplate(tc.tag, tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr != "" {
+				return
+			}
+
+			got := execute(t, out.(*template.Template))
+			if got != tc.want {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fmt.Fprintln(fp, "greeting Hello, {{.}}")
+	fmt.Fprintln(fp, "custom <<.>> {{.}}")
+	fmt.Fprintln(fp, "page one {{.}}")
+	fmt.Fprintln(fp, "page two {{.}}")
+	fp.Close()
+
+	var c struct {
+		Greeting *template.Template
+		Custom   *template.Template `sconfig:"delims=<< >>"`
+		Pages    []*template.Template
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := execute(t, c.Greeting); got != "Hello, world" {
+		t.Errorf("Greeting: %q", got)
+	}
+	if got := execute(t, c.Custom); got != "world {{.}}" {
+		t.Errorf("Custom: %q", got)
+	}
+	if len(c.Pages) != 2 {
+		t.Fatalf("len(Pages) = %d", len(c.Pages))
+	}
+	if got := execute(t, c.Pages[1]); got != "two world" {
+		t.Errorf("Pages[1]: %q", got)
+	}
+
+	t.Run("error", func(t *testing.T) {
+		fp, err := ioutil.TempFile("", "sconfig_template")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(fp.Name())
+		fmt.Fprintln(fp, "greeting Hello")
+		fmt.Fprintln(fp, "greeting {{.")
+		fp.Close()
+
+		err = sconfig.Parse(&c, fp.Name(), nil)
+		if !errorContains(err, "line 2: error parsing greeting: template: :1:") {
+			t.Errorf("wrong error: %v", err)
+		}
+	})
+}
+
+func TestHTMLTemplate(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fmt.Fprintln(fp, "html <b>{{.}}</b>")
+	fp.Close()
+
+	var c struct{ HTML *htmltemplate.Template }
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if !errorContains(err, "don't know how to set fields of the type *template.Template") {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	sconfig.RegisterType("*html/template.Template", func(v []string) (interface{}, error) {
+		return htmltemplate.New("").Parse(strings.Join(v, " "))
+	})
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := c.HTML.Execute(buf, "<x>"); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "<b>&lt;x&gt;</b>" {
+		t.Errorf("HTML: %q", got)
+	}
+}
+
+func delims(d string) sconfig.Tag {
+	return sconfig.Tag{Options: map[string]string{"delims": d}}
+}
+
+func execute(t *testing.T, tpl *template.Template) string {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	err := tpl.Execute(buf, "world")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
@@ -0,0 +1,63 @@
+// Package time contains handlers for parsing values with the time package.
+//
+// It currently implements the time.Time type. Values are parsed with the first
+// layout that works (see SetLayouts()):
+//
+//     start   2016-01-02
+//     updated 2016-01-02 15:04:05
+//     expires 2016-01-02T15:04:05Z
+//
+// Values in a []time.Time are separated by whitespace, so layouts with spaces
+// can't be used there.
+package time
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"zgo.at/sconfig"
+)
+
+// layouts to try, in order.
+var layouts = []string{time.RFC3339, "2006-01-02", "2006-01-02 15:04:05"}
+
+// SetLayouts sets the layouts to try when parsing a time.Time; the first that
+// parses the value is used.
+//
+// This applies globally, and should be called before parsing.
+func SetLayouts(l ...string) {
+	layouts = l
+}
+
+func init() {
+	sconfig.RegisterType("time.Time", sconfig.ValidateValueLimit(1, 0), handleTime)
+	sconfig.RegisterType("[]time.Time", sconfig.ValidateValueLimit(1, 0), handleTimeSlice)
+}
+
+func handleTime(v []string) (interface{}, error) {
+	return parse(strings.Join(v, " "))
+}
+
+func handleTimeSlice(v []string) (interface{}, error) {
+	a := make([]time.Time, len(v))
+	for i := range v {
+		t, err := parse(v[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = t
+	}
+	return a, nil
+}
+
+func parse(s string) (time.Time, error) {
+	for _, l := range layouts {
+		t, err := time.Parse(l, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse %q as a time; tried the layouts %q",
+		s, layouts)
+}
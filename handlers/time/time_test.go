@@ -0,0 +1,97 @@
+package time
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"zgo.at/sconfig"
+)
+
+func TestTime(t *testing.T) {
+	date := time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC)
+	dt := time.Date(2016, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    interface{}
+		wantErr string
+	}{
+		{handleTime, []string{"2016-01-02"}, date, ""},
+		{handleTime, []string{"2016-01-02", "15:04:05"}, dt, ""},
+		{handleTime, []string{"2016-01-02T15:04:05Z"}, dt, ""},
+		{handleTime, []string{"2016"}, nil, `unable to parse "2016" as a time; tried the layouts ["2006-01-02T15:04:05Z07:00" "2006-01-02" "2006-01-02 15:04:05"]`},
+		{handleTime, []string{"2016-13-01"}, nil, `unable to parse "2016-13-01" as a time`},
+
+		{handleTimeSlice, []string{"2016-01-02", "2016-01-02T15:04:05Z"}, []time.Time{date, dt}, ""},
+		{handleTimeSlice, []string{"2016-01-02", "x"}, nil, `unable to parse "x" as a time`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestSetLayouts(t *testing.T) {
+	defer SetLayouts(layouts...)
+	SetLayouts("02/01/2006")
+
+	out, err := handleTime([]string{"02/01/2016"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2016, 1, 2, 0, 0, 0, 0, time.UTC); !out.(time.Time).Equal(want) {
+		t.Errorf("wrong: %v", out)
+	}
+
+	_, err = handleTime([]string{"2016-01-02"})
+	if !errorContains(err, `tried the layouts ["02/01/2006"]`) {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("start-date 2016-01-02\nupdated 2016-01-02 15:04:05\n")
+	fp.Close()
+
+	var c struct {
+		StartDate time.Time
+		Updated   time.Time
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.StartDate.Format("2006-01-02") != "2016-01-02" ||
+		c.Updated.Format("2006-01-02 15:04:05") != "2016-01-02 15:04:05" {
+		t.Errorf("wrong: %v", c)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
@@ -0,0 +1,47 @@
+// Package tz contains handlers for time zones.
+//
+// It currently implements the *time.Location type. Values are loaded with
+// time.LoadLocation(), so any IANA time zone name can be used, as well as
+// "UTC" and "Local":
+//
+//     timezone Europe/Amsterdam
+//
+// This needs the time zone database on the system; import time/tzdata to
+// embed it in the binary if it may not be available.
+package tz
+
+import (
+	"fmt"
+	"time"
+
+	"zgo.at/sconfig"
+)
+
+func init() {
+	sconfig.RegisterType("*time.Location", sconfig.ValidateSingleValue(), handleLocation)
+	sconfig.RegisterType("[]*time.Location", sconfig.ValidateValueLimit(1, 0), handleLocationSlice)
+}
+
+func handleLocation(v []string) (interface{}, error) {
+	return load(v[0])
+}
+
+func handleLocationSlice(v []string) (interface{}, error) {
+	a := make([]*time.Location, len(v))
+	for i := range v {
+		l, err := load(v[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = l
+	}
+	return a, nil
+}
+
+func load(name string) (*time.Location, error) {
+	l, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %v", name, err)
+	}
+	return l, nil
+}
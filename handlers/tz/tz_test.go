@@ -0,0 +1,75 @@
+package tz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"zgo.at/sconfig"
+)
+
+func TestLocation(t *testing.T) {
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    string
+		wantErr string
+	}{
+		{handleLocation, []string{"Europe/Amsterdam"}, "Europe/Amsterdam", ""},
+		{handleLocation, []string{"UTC"}, "UTC", ""},
+		{handleLocation, []string{"Local"}, "Local", ""},
+		{handleLocation, []string{"Europe/Nowhere"}, "", `invalid time zone "Europe/Nowhere": unknown time zone Europe/Nowhere`},
+		{handleLocation, []string{"../etc"}, "", `invalid time zone "../etc": time: invalid location name`},
+
+		{handleLocationSlice, []string{"UTC", "Asia/Tokyo"}, "[UTC Asia/Tokyo]", ""},
+		{handleLocationSlice, []string{"UTC", "Nope"}, "", `invalid time zone "Nope"`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && fmt.Sprintf("%v", out) != tc.want {
+				t.Errorf("\nwant: %s\nout:  %v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_tz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("timezone Europe/Amsterdam\nzones UTC Asia/Tokyo\n")
+	fp.Close()
+
+	var c struct {
+		Timezone *time.Location
+		Zones    []*time.Location
+	}
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Timezone.String() != "Europe/Amsterdam" || fmt.Sprintf("%v", c.Zones) != "[UTC Asia/Tokyo]" {
+		t.Errorf("wrong: %v %v", c.Timezone, c.Zones)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
@@ -0,0 +1,46 @@
+// Package uuid contains handlers for parsing values with the
+// github.com/google/uuid package.
+//
+// It currently implements the uuid.UUID types:
+//
+//     tenant 123e4567-e89b-12d3-a456-426614174000
+//
+// All the forms that uuid.Parse() accepts can be used, such as
+// "urn:uuid:123e4567-e89b-12d3-a456-426614174000".
+package uuid
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"zgo.at/sconfig"
+)
+
+func init() {
+	sconfig.RegisterType("uuid.UUID", sconfig.ValidateSingleValue(), handleUUID)
+	sconfig.RegisterType("[]uuid.UUID", sconfig.ValidateValueLimit(1, 0), handleUUIDSlice)
+}
+
+func handleUUID(v []string) (interface{}, error) {
+	return parse(v[0])
+}
+
+func handleUUIDSlice(v []string) (interface{}, error) {
+	a := make([]uuid.UUID, len(v))
+	for i := range v {
+		u, err := parse(v[i])
+		if err != nil {
+			return nil, err
+		}
+		a[i] = u
+	}
+	return a, nil
+}
+
+func parse(s string) (uuid.UUID, error) {
+	u, err := uuid.Parse(s)
+	if err != nil {
+		return u, fmt.Errorf("%q: %v", s, err)
+	}
+	return u, nil
+}
@@ -0,0 +1,75 @@
+package uuid
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"zgo.at/sconfig"
+)
+
+func TestUUID(t *testing.T) {
+	u := uuid.MustParse("123e4567-e89b-12d3-a456-426614174000")
+	u2 := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	cases := []struct {
+		fun     sconfig.TypeHandler
+		in      []string
+		want    interface{}
+		wantErr string
+	}{
+		{handleUUID, []string{"123e4567-e89b-12d3-a456-426614174000"}, u, ""},
+		{handleUUID, []string{"123E4567-E89B-12D3-A456-426614174000"}, u, ""},
+		{handleUUID, []string{"urn:uuid:123e4567-e89b-12d3-a456-426614174000"}, u, ""},
+		{handleUUID, []string{"123e4567"}, nil, `"123e4567": invalid UUID length: 8`},
+		{handleUUID, []string{"123e4567-e89b-12d3-a456-42661417400x"}, nil, `"123e4567-e89b-12d3-a456-42661417400x": invalid UUID format`},
+
+		{handleUUIDSlice, []string{u.String(), u2.String()}, []uuid.UUID{u, u2}, ""},
+		{handleUUIDSlice, []string{u.String(), "x"}, nil, `"x": invalid UUID length: 1`},
+	}
+
+	for i, tc := range cases {
+		t.Run(fmt.Sprintf("%v", i), func(t *testing.T) {
+			out, err := tc.fun(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Errorf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	fp, err := ioutil.TempFile("", "sconfig_uuid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fp.Name())
+	fp.WriteString("tenant 123e4567-e89b-12d3-a456-426614174000\ntenant 123e4567-e89b-12d3-a456-426614174000 x\n")
+	fp.Close()
+
+	var c struct{ Tenant uuid.UUID }
+	err = sconfig.Parse(&c, fp.Name(), nil)
+	if !errorContains(err, "line 2: error parsing tenant: must have exactly one value") {
+		t.Fatalf("wrong error: %v", err)
+	}
+	if c.Tenant.String() != "123e4567-e89b-12d3-a456-426614174000" {
+		t.Errorf("wrong: %v", c.Tenant)
+	}
+}
+
+func errorContains(out error, want string) bool {
+	if out == nil {
+		return want == ""
+	}
+	if want == "" {
+		return false
+	}
+	return strings.Contains(out.Error(), want)
+}
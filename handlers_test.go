@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestHandlers(t *testing.T) {
@@ -25,6 +26,20 @@ func TestHandlers(t *testing.T) {
 		{handleBool, []string{}, true, ""},
 		{handleBool, []string{"it is true"}, nil, `unable to parse "it is true" as a boolean`},
 
+		{handleBool, []string{"2"}, nil, `unable to parse "2" as a boolean`},
+
+		{HandleNumericBool, []string{"0"}, false, ""},
+		{HandleNumericBool, []string{"1"}, true, ""},
+		{HandleNumericBool, []string{"2"}, true, ""},
+		{HandleNumericBool, []string{"-1"}, true, ""},
+		{HandleNumericBool, []string{"+0"}, false, ""},
+		{HandleNumericBool, []string{"off"}, false, ""},
+		{HandleNumericBool, []string{"Yes"}, true, ""},
+		{HandleNumericBool, []string{}, true, ""},
+		{HandleNumericBool, []string{"1.5"}, nil, `unable to parse "1.5" as a boolean`},
+		{HandleNumericBoolSlice, []string{"0", "2", "-1", "no"}, []bool{false, true, true, false}, ""},
+		{HandleNumericBoolSlice, []string{"0", "x"}, nil, `unable to parse "x" as a boolean`},
+
 		{handleFloat32, []string{}, nil, `strconv.ParseFloat: parsing "": invalid syntax`},
 		{handleFloat32, []string{"0.0"}, float32(0.0), ""},
 		{handleFloat32, []string{".000001"}, float32(0.000001), ""},
@@ -37,9 +52,60 @@ func TestHandlers(t *testing.T) {
 		{handleFloat64, []string{"1"}, float64(1), ""},
 		{handleFloat64, []string{"1.1", "12"}, float64(1.112), ""},
 
+		{handleFloat64, []string{"+1.5"}, float64(1.5), ""},
+		{handleFloat64, []string{"++1.5"}, nil, `strconv.ParseFloat: parsing "++1.5": invalid syntax`},
+
+		{handleComplex64, []string{"(1+2i)"}, complex64(1 + 2i), ""},
+		{handleComplex64, []string{"1.5-2i"}, complex64(1.5 - 2i), ""},
+		{handleComplex64, []string{"3"}, complex64(3), ""},
+		{handleComplex64, []string{"1+2j"}, nil, `strconv.ParseComplex: parsing "1+2j": invalid syntax`},
+		{handleComplex128, []string{"(1+2i)"}, complex128(1 + 2i), ""},
+		{handleComplex128, []string{"+2i"}, complex128(2i), ""},
+		{handleComplex128, []string{"(1+2i"}, nil, `strconv.ParseComplex: parsing "(1+2i": invalid syntax`},
+		{handleComplex64Slice, []string{"1+2i", "3i"}, []complex64{1 + 2i, 3i}, ""},
+		{handleComplex128Slice, []string{"1+2i", "(-1-1i)"}, []complex128{1 + 2i, -1 - 1i}, ""},
+		{handleComplex128Slice, []string{"1+2i", "x"}, nil, `strconv.ParseComplex: parsing "x": invalid syntax`},
+
+		{HandleRune, []string{";"}, ';', ""},
+		{HandleRune, []string{"59"}, ';', ""},
+		{HandleRune, []string{"€"}, '€', ""},
+		{HandleRune, []string{"5"}, rune(5), ""},
+		{HandleRune, []string{"ab"}, nil, `unable to parse "ab" as a character or number`},
+		{HandleRune, []string{"99999999999"}, nil, `unable to parse "99999999999" as a character or number`},
+		{HandleByte, []string{";"}, byte(';'), ""},
+		{HandleByte, []string{"59"}, byte(';'), ""},
+		{HandleByte, []string{"€"}, nil, `unable to parse "€" as an ASCII character or number`},
+		{HandleByte, []string{"256"}, nil, `unable to parse "256" as an ASCII character or number`},
+
+		{HandleInt64, []string{"+8080"}, int64(8080), ""},
+		{HandleInt64, []string{"-8080"}, int64(-8080), ""},
+		{HandleInt64, []string{"+-8080"}, nil, `strconv.ParseInt: parsing "+-8080": invalid syntax`},
+		{HandleInt64, []string{"8080+"}, nil, `strconv.ParseInt: parsing "8080+": invalid syntax`},
+		{handleUint64, []string{"+8080"}, uint64(8080), ""},
+		{handleUint64, []string{"+"}, nil, `strconv.ParseUint: parsing "+": invalid syntax`},
+		{handleUint64, []string{"++8080"}, nil, `strconv.ParseUint: parsing "++8080": invalid syntax`},
+
+		{handleInt64Slice, []string{"+1", "-2", "3"}, []int64{1, -2, 3}, ""},
+		{handleUint64Slice, []string{"+1", "2"}, []uint64{1, 2}, ""},
+		{handleUint64Slice, []string{"1", "+-2"}, nil, `strconv.ParseUint: parsing "+-2": invalid syntax`},
+		{handleFloat32Slice, []string{"+1.5", "2"}, []float32{1.5, 2}, ""},
+
 		{handleStringMap, []string{"a", "b"}, map[string]string{"a": "b"}, ""},
 		{handleStringMap, []string{"a", "b", "x", "y"}, map[string]string{"a": "b", "x": "y"}, ""},
 		{handleStringMap, []string{"a", "b", "x"}, nil, "uneven number of arguments: 3"},
+		{handleStringSliceMap, []string{"a", "b"}, map[string][]string{"a": {"b"}}, ""},
+		{handleStringSliceMap, []string{"a", "b", "c"}, map[string][]string{"a": {"b", "c"}}, ""},
+
+		{HandleDuration, []string{"30s"}, 30 * time.Second, ""},
+		{HandleDuration, []string{"1h30m"}, 90 * time.Minute, ""},
+		{HandleDuration, []string{"30x"}, nil, `unable to parse "30x" as a duration`},
+		{handleDurationSlice, []string{"1s", "2m"}, []time.Duration{time.Second, 2 * time.Minute}, ""},
+		{handleDurationSlice, []string{"1s", "2x"}, nil, `unable to parse "2x" as a duration`},
+
+		{handlePairSlice, []string{"b=1", "a=2", "c"}, []Pair{{"b", "1"}, {"a", "2"}, {"c", ""}}, ""},
+		{handlePairSlice, []string{"auth", "logging"}, []Pair{{"auth", ""}, {"logging", ""}}, ""},
+		{handlePairSlice, []string{"a=b=c", "x="}, []Pair{{"a", "b=c"}, {"x", ""}}, ""},
+		{handlePairSlice, []string{"a=1", "=2"}, nil, `empty key in "=2"`},
 	}
 
 	for i, tc := range cases {
@@ -64,3 +130,53 @@ func errorContains(out error, want string) bool {
 	}
 	return strings.Contains(out.Error(), want)
 }
+
+func TestParseDuration(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr string
+	}{
+		// Standard units still work.
+		{"0", 0, ""},
+		{"5m", 5 * time.Minute, ""},
+		{"2h", 2 * time.Hour, ""},
+		{"1h30m", 90 * time.Minute, ""},
+		{"1.5h", 90 * time.Minute, ""},
+		{"300ms", 300 * time.Millisecond, ""},
+		{"-5m", -5 * time.Minute, ""},
+		{"+5m", 5 * time.Minute, ""},
+
+		{"30d", 30 * day, ""},
+		{"2w", 14 * day, ""},
+		{"1.5d", 36 * time.Hour, ""},
+		{"1mo", 30 * day, ""},
+		{"1y", 365 * day, ""},
+		{"1w3d", 10 * day, ""},
+		{"1d12h", 36 * time.Hour, ""},
+		{"1y6mo", 545 * day, ""},
+		{"2d1h30m5s", 2*day + time.Hour + 30*time.Minute + 5*time.Second, ""},
+		{"-1w1d", -8 * day, ""},
+
+		{"", 0, `unable to parse "" as a duration`},
+		{"5", 0, `unable to parse "5" as a duration`},
+		{"d", 0, `unable to parse "d" as a duration`},
+		{"30x", 0, `unable to parse "30x" as a duration`},
+		{"1.2.3d", 0, `unable to parse "1.2.3d" as a duration`},
+		{"1d-5h", 0, `unable to parse "1d-5h" as a duration`},
+		{"99999999y", 0, `unable to parse "99999999y" as a duration`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			out, err := parseDuration(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if out != tc.want {
+				t.Errorf("\nwant: %s\nout:  %s\n", tc.want, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,438 @@
+package sconfig
+
+// This file contains the conversion of a struct back to the config format.
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// marshalers are the registered functions to format a type for Marshal(); the
+// key is the name of the type, as with typeHandlers.
+var marshalers = make(map[string]func(interface{}) (string, error))
+
+// RegisterMarshaler sets the function to format a value of the type for
+// Marshal().
+//
+// This is only needed for types with a type handler that don't implement
+// encoding.TextMarshaler, and can't be written with String() or as a plain
+// number in a way that the type handler reads back; Marshal() returns an error
+// for those. RegisterEnum() sets this for the enum type.
+func RegisterMarshaler(typ string, fun func(interface{}) (string, error)) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	marshalers[typ] = fun
+}
+
+// marshaler gets the marshal function for the type, using the qualified name if
+// it's registered, or the short name if it's not.
+func marshaler(typ reflect.Type) (func(interface{}) (string, error), bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	if m, ok := marshalers[qualifiedName(typ)]; ok {
+		return m, ok
+	}
+	m, ok := marshalers[typ.String()]
+	return m, ok
+}
+
+// Marshal writes the struct in the sconfig format, which can be parsed again
+// with Parse().
+//
+// Every field is written as "key value", where the key is the name in the
+// struct tag, or the field name with the inverse of the inference that Parse()
+// uses ("BaseURL" becomes "base-url"). Slices are written as a space-separated
+// list on one line, and slices of slices or structs as one line for every
+// element. Nested structs and maps are written with dotted keys ("tls.cert
+// a.pem").
+//
+// Whitespace, "#", and "\" in values are escaped so they're read back
+// unchanged, but values in a slice can't contain whitespace and newlines can't
+// be written at all; this is an error.
+//
+// Values of a type with a type handler are written with the function from
+// RegisterMarshaler(), or else with String() or as a number if that's read back
+// as the same value; it's an error if none of these work.
+//
+// Fields with the zero value are skipped, unless the struct tag has the
+// emitempty option:
+//
+//     Port int64 `sconfig:",emitempty"`
+//
+// Nil pointers and empty slices and maps are always skipped, as there is
+// nothing to write.
+func Marshal(c interface{}) ([]byte, error) {
+	v := reflect.ValueOf(c)
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sconfig.Marshal: can only marshal structs, not %s", v.Kind())
+	}
+
+	var b bytes.Buffer
+	err := marshalStruct(&b, "", v)
+	return b.Bytes(), err
+}
+
+// Effective gets the current values of the config struct in the sconfig
+// format; this is the same as Marshal().
+//
+// This is useful to see which config was actually loaded after setting
+// defaults, parsing one or more files, and applying overrides from flags or the
+// environment; for example to write it to a log.
+func Effective(c interface{}) ([]byte, error) {
+	return Marshal(c)
+}
+
+func marshalStruct(b *bytes.Buffer, prefix string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" { // Unexported
+			continue
+		}
+		fv := v.Field(i)
+		tag := parseTag(f.Tag)
+		if fv.IsZero() && !tag.Has("emitempty") {
+			continue
+		}
+		switch fv.Kind() {
+		case reflect.Ptr:
+			if fv.IsNil() {
+				continue
+			}
+		case reflect.Slice, reflect.Map:
+			if fv.Len() == 0 {
+				continue
+			}
+		}
+
+		key := tag.Name
+		if key == "" {
+			key = keyFromFieldName(f.Name)
+		}
+		key = prefix + key
+
+		if fv.Kind() == reflect.Ptr {
+			fv = fv.Elem()
+		}
+		switch {
+		case fv.Kind() == reflect.Struct && !isScalar(fv):
+			if err := marshalStruct(b, key+".", fv); err != nil {
+				return err
+			}
+			continue
+		case fv.Kind() == reflect.Map:
+			if err := marshalMap(b, key+".", fv); err != nil {
+				return err
+			}
+			continue
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Slice &&
+			fv.Type().Elem().Elem().Kind() != reflect.Uint8:
+			// One line for every row.
+			for j := 0; j < fv.Len(); j++ {
+				val, err := marshalValue(fv.Index(j))
+				if err != nil {
+					return fmt.Errorf("sconfig.Marshal: field %s: %v", f.Name, err)
+				}
+				b.WriteString(line(key, val))
+			}
+			continue
+		case isStructSlice(fv):
+			// One line for every element.
+			for j := 0; j < fv.Len(); j++ {
+				val, err := marshalFields(fv.Index(j))
+				if err != nil {
+					return fmt.Errorf("sconfig.Marshal: field %s: %v", f.Name, err)
+				}
+				b.WriteString(line(key, val))
+			}
+			continue
+		}
+
+		val, err := marshalValue(fv)
+		if err != nil {
+			return fmt.Errorf("sconfig.Marshal: field %s: %v", f.Name, err)
+		}
+		b.WriteString(line(key, val))
+	}
+	return nil
+}
+
+func marshalMap(b *bytes.Buffer, prefix string, m reflect.Value) error {
+	lines := make([]string, 0, m.Len())
+	iter := m.MapRange()
+	for iter.Next() {
+		k, err := marshalScalar(iter.Key())
+		if err == nil && (k == "" || strings.IndexFunc(k, unicode.IsSpace) > -1) {
+			err = fmt.Errorf("can't marshal %q: map keys can't be empty or contain whitespace", k)
+		}
+		if err != nil {
+			return fmt.Errorf("sconfig.Marshal: map key in %s: %v", strings.TrimSuffix(prefix, "."), err)
+		}
+		val, err := marshalValue(iter.Value())
+		if err != nil {
+			return fmt.Errorf("sconfig.Marshal: %s%s: %v", prefix, k, err)
+		}
+		lines = append(lines, line(prefix+k, val))
+	}
+	sort.Strings(lines)
+	for _, l := range lines {
+		b.WriteString(l)
+	}
+	return nil
+}
+
+func line(key, val string) string {
+	// Would be read back as a "source" line.
+	if SourceKeyword != "" && key == SourceKeyword {
+		key = `\` + key
+	}
+	if val == "" {
+		return key + "\n"
+	}
+	// Would be read back as clearing the slice.
+	if ClearKeyword != "" && (val == ClearKeyword || strings.HasPrefix(val, ClearKeyword+" ")) {
+		val = `\` + val
+	}
+	return key + " " + val + "\n"
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// isScalar reports if a struct is written as a single value, rather than as
+// separate fields. This is the case for all structs that are read as a single
+// value (see isValue()), and structs that implement encoding.TextMarshaler.
+func isScalar(v reflect.Value) bool {
+	_, ok := v.Interface().(encoding.TextMarshaler)
+	return ok || isValue(v.Type())
+}
+
+// isStructSlice reports if v is a slice of structs (or pointers to structs)
+// without a type handler, which are written as one line for every element.
+func isStructSlice(v reflect.Value) bool {
+	if v.Kind() != reflect.Slice {
+		return false
+	}
+	if _, ok := typeHandler(v.Type()); ok {
+		return false
+	}
+	t := v.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !t.Implements(textMarshalerType) &&
+		!reflect.PtrTo(t).Implements(textMarshalerType)
+}
+
+// marshalFields formats all exported fields of a struct as a space-separated
+// list.
+func marshalFields(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", fmt.Errorf("can't marshal nil pointers in a slice")
+		}
+		v = v.Elem()
+	}
+	vals := make([]string, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).PkgPath != "" { // Unexported
+			continue
+		}
+		s, err := marshalElem(v.Field(i))
+		if err != nil {
+			return "", err
+		}
+		vals = append(vals, s)
+	}
+	return strings.Join(vals, " "), nil
+}
+
+// marshalValue formats a value; slices are formatted as a space-separated
+// list.
+func marshalValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		vals := make([]string, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s, err := marshalElem(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			vals[i] = s
+		}
+		return strings.Join(vals, " "), nil
+	}
+
+	s, err := marshalScalar(v)
+	if err != nil {
+		return "", err
+	}
+	return escape(s)
+}
+
+// marshalElem formats a single value in a list of values, which can't be empty
+// or contain whitespace.
+func marshalElem(v reflect.Value) (string, error) {
+	s, err := marshalScalar(v)
+	if err != nil {
+		return "", err
+	}
+	if s == "" || strings.IndexFunc(s, unicode.IsSpace) > -1 {
+		return "", fmt.Errorf("can't marshal %q: slice values can't be empty or contain whitespace", s)
+	}
+	return escape(s)
+}
+
+// marshalScalar formats a single value, without escaping.
+func marshalScalar(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+			t, err := m.MarshalText()
+			return string(t), err
+		}
+		v = v.Elem()
+	}
+
+	switch vv := v.Interface().(type) {
+	case encoding.TextMarshaler:
+		t, err := vv.MarshalText()
+		return string(t), err
+	case time.Duration:
+		return vv.String(), nil
+	case Pair:
+		if vv.Value == "" {
+			return vv.Key, nil
+		}
+		return vv.Key + "=" + vv.Value, nil
+	}
+
+	// Types with a type handler may not be read back as the number or string
+	// they're stored as (e.g. "0644" for os.FileMode).
+	if v.Type().PkgPath() != "" {
+		if _, ok := typeHandler(v.Type()); ok {
+			return marshalHandlerValue(v)
+		}
+	}
+
+	s, err := marshalKind(v)
+	if err == nil {
+		return s, nil
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String(), nil
+	}
+	return "", err
+}
+
+// marshalKind formats a value based on its kind.
+func marshalKind(v reflect.Value) (string, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case reflect.Complex64:
+		return strconv.FormatComplex(v.Complex(), 'g', -1, 64), nil
+	case reflect.Complex128:
+		return strconv.FormatComplex(v.Complex(), 'g', -1, 128), nil
+	}
+	return "", fmt.Errorf("don't know how to marshal the type %s", v.Type())
+}
+
+// marshalHandlerValue formats a value of a type with a type handler: with the
+// function from RegisterMarshaler(), or else with String() or marshalKind() if
+// the type handler reads the result back as the same value.
+func marshalHandlerValue(v reflect.Value) (string, error) {
+	if m, ok := marshaler(v.Type()); ok {
+		return m(v.Interface())
+	}
+
+	var try []string
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		try = append(try, s.String())
+	}
+	if s, err := marshalKind(v); err == nil {
+		try = append(try, s)
+	}
+	for _, s := range try {
+		var values []string
+		if s != "" {
+			values = strings.Split(s, " ")
+		}
+		read := reflect.New(v.Type()).Elem()
+		if _, err := setFromTypeHandler(&read, Tag{}, values); err != nil {
+			continue
+		}
+		if reflect.DeepEqual(read.Interface(), v.Interface()) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("can't marshal the type %s: the type handler doesn't read "+
+		"back the value %v; use RegisterMarshaler()", v.Type(), v.Interface())
+}
+
+// escape a value so it's read back as-is: "\" and CommentChars are escaped
+// with a "\", as is whitespace after other whitespace (the value is always
+// preceded by the space after the key, so this includes leading whitespace).
+//
+// Not everything can be escaped: newlines, trailing whitespace, and whitespace
+// other than a space that isn't preceded by whitespace are an error.
+func escape(s string) (string, error) {
+	var b strings.Builder
+	prevSpace := true
+	for _, c := range s {
+		switch {
+		case c == '\n' || c == '\r':
+			return "", fmt.Errorf("can't marshal %q: newlines can't be escaped", s)
+		case c == '\\' || isComment(c):
+			b.WriteRune('\\')
+		case unicode.IsSpace(c) && prevSpace:
+			b.WriteRune('\\')
+		case unicode.IsSpace(c) && c != ' ':
+			return "", fmt.Errorf("can't marshal %q: %q must be preceded by a space", s, c)
+		}
+		b.WriteRune(c)
+		prevSpace = unicode.IsSpace(c)
+	}
+	if prevSpace && s != "" {
+		return "", fmt.Errorf("can't marshal %q: trailing whitespace can't be escaped", s)
+	}
+	return b.String(), nil
+}
+
+// keyFromFieldName is the inverse of fieldNameFromKey: "BaseURL" becomes
+// "base-url".
+func keyFromFieldName(name string) string {
+	r := []rune(name)
+	var b strings.Builder
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) {
+			prev := r[i-1]
+			nextLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+			if !unicode.IsUpper(prev) || nextLower {
+				b.WriteRune('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
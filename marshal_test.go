@@ -0,0 +1,239 @@
+package sconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	tests := []interface{}{
+		&testPrimitives{Str: "hello  world #1 \\o/", Int64: -42, UInt64: 42, Bool: true,
+			Float32: 1.5, Float64: -3.25e10},
+		&testArray{Str: []string{"a", "b#", "c\\d"}, Int64: []int64{1, -2},
+			UInt64: []uint64{3}, Bool: []bool{true, false}, Float32: []float32{0.5},
+			Float64: []float64{1e100, 2}},
+		&testPrimitives{},
+		&struct {
+			C64  complex64
+			C128 complex128
+			Cs   []complex128
+		}{1.5 - 2i, complex(1e100, 0.1), []complex128{1i, -3}},
+		&struct{ Source, Name string }{"foo", "x"},
+		&struct {
+			Tags  []string
+			Name  string
+			Pairs []struct{ A, B string }
+		}{[]string{"!clear", "a", "!clear"}, "!clear", []struct{ A, B string }{{"!clear", "x"}}},
+	}
+
+	for _, in := range tests {
+		t.Run("", func(t *testing.T) {
+			b, err := Marshal(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			f := testfile(string(b))
+			defer rm(t, f)
+
+			out := reflect.New(reflect.TypeOf(in).Elem()).Interface()
+			err = Parse(out, f, nil)
+			if err != nil {
+				t.Fatalf("%s\n%v", b, err)
+			}
+			if !reflect.DeepEqual(in, out) {
+				t.Errorf("\nwant: %#v\nout:  %#v\nmarshaled:\n%s", in, out, b)
+			}
+		})
+	}
+}
+
+type testPoint struct{ X, Y int64 }
+
+func (p testPoint) String() string { return fmt.Sprintf("%d,%d", p.X, p.Y) }
+
+func TestMarshalTypeHandler(t *testing.T) {
+	defer func() {
+		handlersMu.Lock()
+		delete(typeHandlers, "sconfig.testPoint")
+		handlersMu.Unlock()
+	}()
+	RegisterType("sconfig.testPoint", ValidateSingleValue(), func(v []string) (interface{}, error) {
+		var p testPoint
+		_, err := fmt.Sscanf(v[0], "%d,%d", &p.X, &p.Y)
+		return p, err
+	})
+
+	type config struct {
+		Point testPoint
+		Ptr   *testPoint
+	}
+	in := config{Point: testPoint{1, 2}, Ptr: &testPoint{3, 4}}
+	b, err := Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "point 1,2\nptr 3,4\n"; string(b) != want {
+		t.Errorf("\nwant: %q\nout:  %q\n", want, b)
+	}
+
+	f := testfile(string(b))
+	defer rm(t, f)
+	var out config
+	if err := Parse(&out, f, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", in, out)
+	}
+}
+
+type testMarshalMode int
+
+func TestMarshalEnum(t *testing.T) {
+	defer func() {
+		handlersMu.Lock()
+		delete(typeHandlers, "sconfig.testMarshalMode")
+		delete(typeHandlers, "[]sconfig.testMarshalMode")
+		delete(marshalers, "sconfig.testMarshalMode")
+		handlersMu.Unlock()
+	}()
+	RegisterEnum("sconfig.testMarshalMode", map[string]interface{}{
+		"fast":  testMarshalMode(1),
+		"quick": testMarshalMode(1),
+		"safe":  testMarshalMode(2),
+	})
+
+	type config struct {
+		Mode  testMarshalMode
+		Modes []testMarshalMode
+	}
+	in := config{Mode: 2, Modes: []testMarshalMode{1, 2}}
+	b, err := Marshal(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "mode safe\nmodes fast safe\n"; string(b) != want {
+		t.Errorf("\nwant: %q\nout:  %q\n", want, b)
+	}
+
+	f := testfile(string(b))
+	defer rm(t, f)
+	var out config
+	if err := Parse(&out, f, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", in, out)
+	}
+
+	_, err = Marshal(&config{Mode: 3})
+	if !errorContains(err, "no name for the value 3 (valid: fast, quick, safe)") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+// A type handler that doesn't read back what String() or the number gives is
+// an error, rather than writing a value that's read back as something else.
+func TestMarshalNoWriteBack(t *testing.T) {
+	defer func() {
+		handlersMu.Lock()
+		delete(typeHandlers, "sconfig.testMarshalMode")
+		handlersMu.Unlock()
+	}()
+	RegisterType("sconfig.testMarshalMode", ValidateSingleValue(), func(v []string) (interface{}, error) {
+		n, err := strconv.ParseInt(v[0], 8, 64)
+		return testMarshalMode(n), err
+	})
+
+	b, err := Marshal(&struct{ Mode testMarshalMode }{010})
+	if !errorContains(err, "can't marshal the type sconfig.testMarshalMode") {
+		t.Errorf("wrong error: %v\n%s", err, b)
+	}
+
+	// Values that happen to read back fine are written.
+	b, err = Marshal(&struct{ Mode testMarshalMode }{7})
+	if err != nil || string(b) != "mode 7\n" {
+		t.Errorf("%q %v", b, err)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		in      interface{}
+		want    string
+		wantErr string
+	}{
+		{struct{ BaseURL, UInt64 string }{"x", "y"}, "base-url x\nu-int64 y\n", ""},
+		{struct {
+			A string `sconfig:"name"`
+			B int64  `sconfig:",emitempty"`
+			C bool   `sconfig:"c,emitempty"`
+			D string `sconfig:",emitempty"`
+			E int64
+			F []string `sconfig:",emitempty"`
+			G *int64   `sconfig:",emitempty"`
+		}{A: "x"}, "name x\nb 0\nc false\nd\n", ""},
+		{struct{ T time.Duration }{time.Minute}, "t 1m0s\n", ""},
+		{struct{ C complex128 }{1 + 2i}, "c (1+2i)\n", ""},
+		{struct{ P []Pair }{[]Pair{{Key: "a", Value: "b c"}}}, "", `"a=b c": slice values can't be empty or contain whitespace`},
+		{struct{ S string }{"a\nb"}, "", "newlines can't be escaped"},
+		{struct{ S string }{"a "}, "", "trailing whitespace can't be escaped"},
+		{struct{ S string }{"a\tb"}, "", `'\t' must be preceded by a space`},
+		{struct{ M map[string]int64 }{map[string]int64{"a b": 1}}, "", "map keys can't be empty or contain whitespace"},
+		{struct{ S []struct{ A, B string } }{[]struct{ A, B string }{{"a", "b"}, {"c", "d"}}}, "s a b\ns c d\n", ""},
+		{struct{ S []*struct{ A int64 } }{[]*struct{ A int64 }{{1}}}, "s 1\n", ""},
+		{struct{ S []struct{ A, B string } }{[]struct{ A, B string }{{"a", ""}}}, "", `can't marshal "": slice values can't be empty`},
+		{struct{ Source string }{"foo"}, "\\source foo\n", ""},
+		{struct{ Database struct{ Source string } }{struct{ Source string }{"x"}}, "database.source x\n", ""},
+		{struct {
+			Src string `sconfig:"source"`
+		}{"x"}, "\\source x\n", ""},
+		{struct{ Tags []string }{[]string{"!clear", "a"}}, "tags \\!clear a\n", ""},
+		{struct{ Tags []string }{[]string{"a", "!clear"}}, "tags a !clear\n", ""},
+		{struct{ Tags []string }{[]string{"!clear-x"}}, "tags !clear-x\n", ""},
+		{"str", "", "can only marshal structs, not string"},
+	}
+
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			out, err := Marshal(tc.in)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if string(out) != tc.want {
+				t.Errorf("\nwant: %q\nout:  %q\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestKeyFromFieldName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Str", "str"},
+		{"BaseURL", "base-url"},
+		{"UInt64", "u-int64"},
+		{"HTTPServer", "http-server"},
+		{"TimeType", "time-type"},
+		{"X", "x"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			out := keyFromFieldName(tc.in)
+			if out != tc.want {
+				t.Errorf("\nwant: %q\nout:  %q", tc.want, out)
+			}
+			if name, err := fieldNameFromKey(out, reflect.ValueOf(&struct {
+				Str, BaseURL, UInt64, HTTPServer, TimeType, X int64
+			}{}).Elem(), false); err != nil || name != tc.in {
+				t.Errorf("doesn't round-trip: %q %v", name, err)
+			}
+		})
+	}
+}
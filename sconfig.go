@@ -5,12 +5,20 @@ package sconfig
 
 import (
 	"bufio"
+	"context"
 	"encoding"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unicode"
 )
 
@@ -20,6 +28,10 @@ var (
 	// The key is the name of the type, the value the list of handler functions
 	// to run.
 	typeHandlers = make(map[string][]TypeHandler)
+
+	// handlersMu guards typeHandlers, tagHandlers, and marshalers, so types can be
+	// registered while other goroutines are parsing.
+	handlersMu sync.RWMutex
 )
 
 // TypeHandler takes the field to set and the value to set it to. It is expected
@@ -35,58 +47,370 @@ type Handler func([]string) error
 // of the field in the struct.
 type Handlers map[string]Handler
 
+// HandlerCtx is like a Handler, but also gets the location of the line; this
+// can be used to give better errors.
+type HandlerCtx func(ctx HandlerContext, values []string) error
+
+// HandlerContext is the location of the line for a HandlerCtx.
+type HandlerContext struct {
+	File string // Filename, as passed to Parse().
+	Line int    // Line number.
+	Key  string // Key as it appears in the file, e.g. "base-url".
+}
+
+// ChainHandlers returns a Handler which runs all the handlers in order. The
+// chain is stopped if a handler returns an error. For example:
+//
+//     Handlers{
+//         "Port": ChainHandlers(validatePort, setPort),
+//     }
+//
+// This is an ordinary Handler, so it can be used everywhere a single Handler
+// can.
+func ChainHandlers(handlers ...Handler) Handler {
+	return func(v []string) error {
+		for _, h := range handlers {
+			if err := h(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
 // RegisterType sets the type handler functions for a type. Existing handlers
 // are always overridden (it doesn't add to the list!)
 //
-// The handlers are chained; the return value is passed to the next one. The
-// chain is stopped if one handler returns a non-nil error. This is particularly
-// useful for validation (see ValidateSingleValue() and ValidateValueLimit() for
-// examples).
+// The last function is the handler which returns the value to set, and all
+// functions before it are validators (see ValidateSingleValue() and
+// ValidateValueLimit() for examples):
+//
+//     RegisterType("net.IP", ValidateSingleValue(), handleIP)
+//
+// They're run in order with the same values; the return value of the
+// validators is ignored, and the chain is stopped at the first non-nil error.
+//
+// The type can also be qualified with the full package path, for types that
+// have the same name as a type in another package:
+//
+//     RegisterType("*text/template.Template", handleTemplate)
+//
+// A handler for the qualified name is used over one for the short name.
+//
+// It will panic if there are no functions.
 func RegisterType(typ string, fun ...TypeHandler) {
+	if len(fun) == 0 {
+		panic(fmt.Sprintf("sconfig.RegisterType: no handler for %q", typ))
+	}
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
 	typeHandlers[typ] = fun
 }
 
+// RegisterFlags sets a type handler for a bitmask type, where every value is
+// the name of a flag. All the named flags on a line are OR'd together; for
+// example with:
+//
+//     RegisterFlags("main.Caps", map[string]uint64{
+//         "read":  1 << 0,
+//         "write": 1 << 1,
+//         "exec":  1 << 2,
+//     })
+//
+// The line "caps read exec" will set the field to 5. It's an error to use a
+// flag name that's not in the map.
+//
+// The field can be of any integer type.
+func RegisterFlags(typ string, flags map[string]uint64) {
+	RegisterType(typ, ValidateValueLimit(1, 0), func(v []string) (interface{}, error) {
+		var r uint64
+		for _, f := range v {
+			bit, ok := flags[f]
+			if !ok {
+				return nil, fmt.Errorf("unknown flag %q", f)
+			}
+			r |= bit
+		}
+		return r, nil
+	})
+}
+
+// RegisterEnum sets the type handlers for an enum type and a slice of it, where
+// every value is the name of a constant; for example:
+//
+//     RegisterEnum("main.Mode", map[string]interface{}{
+//         "fast":         ModeFast,
+//         "safe":         ModeSafe,
+//         "experimental": ModeExperimental,
+//     })
+//
+// Will allow setting a Mode field with "mode fast". The values should all be of
+// the enum's type.
+//
+// The []main.Mode slice type accepts both whitespace and comma-separated names,
+// so "modes fast,safe" and "modes fast safe" are identical.
+//
+// Marshal() writes the name; if several names have the same value the first in
+// alphabetical order is used.
+func RegisterEnum(typ string, values map[string]interface{}) {
+	var elem reflect.Type
+	names := make([]string, 0, len(values))
+	for k, v := range values {
+		elem = reflect.TypeOf(v)
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	valid := strings.Join(names, ", ")
+
+	byValue := make(map[interface{}]string, len(values))
+	for _, n := range names {
+		if _, ok := byValue[values[n]]; !ok {
+			byValue[values[n]] = n
+		}
+	}
+	RegisterMarshaler(typ, func(v interface{}) (string, error) {
+		n, ok := byValue[v]
+		if !ok {
+			return "", fmt.Errorf("no name for the value %v (valid: %s)", v, valid)
+		}
+		return n, nil
+	})
+
+	RegisterType(typ, ValidateSingleValue(), func(v []string) (interface{}, error) {
+		val, ok := values[v[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown value %q (valid: %s)", v[0], valid)
+		}
+		return val, nil
+	})
+	RegisterType("[]"+typ, ValidateValueLimit(1, 0), func(v []string) (interface{}, error) {
+		a := reflect.MakeSlice(reflect.SliceOf(elem), 0, len(v))
+		i := 0
+		for _, vv := range v {
+			for _, name := range strings.Split(vv, ",") {
+				if name == "" {
+					continue
+				}
+				i++
+				val, ok := values[name]
+				if !ok {
+					return nil, fmt.Errorf("unknown value %q at position %d (valid: %s)",
+						name, i, valid)
+				}
+				a = reflect.Append(a, reflect.ValueOf(val))
+			}
+		}
+		return a.Interface(), nil
+	})
+}
+
+// RegisterParseFunc sets the type handlers for the type of sample and a slice
+// of it, using a function that parses a string. This is useful for types that
+// have a Parse() or New() function but don't implement
+// encoding.TextUnmarshaler; for example:
+//
+//     RegisterParseFunc(semver.Version{}, func(v string) (interface{}, error) {
+//         return semver.Parse(v)
+//     })
+//
+// For the type itself all values are joined with a space and passed to parse;
+// for the slice type parse is called for every value.
+func RegisterParseFunc(sample interface{}, parse func(string) (interface{}, error)) {
+	typ := reflect.TypeOf(sample)
+	RegisterType(typ.String(), ValidateValueLimit(1, 0), func(v []string) (interface{}, error) {
+		return parse(strings.Join(v, " "))
+	})
+	RegisterType("[]"+typ.String(), ValidateValueLimit(1, 0), func(v []string) (interface{}, error) {
+		a := reflect.MakeSlice(reflect.SliceOf(typ), 0, len(v))
+		for i := range v {
+			r, err := parse(v[i])
+			if err != nil {
+				return nil, err
+			}
+			a = reflect.Append(a, convert(reflect.ValueOf(r), typ))
+		}
+		return a.Interface(), nil
+	})
+}
+
+// RegisterConstructors sets a type handler for a type where the first value
+// selects a constructor which creates the value from the remaining values. This
+// is useful for func or interface types; for example:
+//
+//     type Matcher func(string) bool
+//
+//     RegisterConstructors("main.Matcher", map[string]TypeHandler{
+//         "prefix": func(v []string) (interface{}, error) {
+//             return func(s string) bool { return strings.HasPrefix(s, v[0]) }, nil
+//         },
+//         "any": func(v []string) (interface{}, error) {
+//             return func(string) bool { return true }, nil
+//         },
+//     })
+//
+// Will allow setting a Matcher field with "match prefix /api". The constructor
+// should return a value that's assignable or convertible to the field's type.
+//
+// It's an error to use a name that's not in the map. Errors from the
+// constructor are returned with the constructor's name prefixed.
+func RegisterConstructors(typ string, ctors map[string]TypeHandler) {
+	names := make([]string, 0, len(ctors))
+	for k := range ctors {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	valid := strings.Join(names, ", ")
+
+	RegisterType(typ, ValidateValueLimit(1, 0), func(v []string) (interface{}, error) {
+		ctor, ok := ctors[v[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown constructor %q (valid: %s)", v[0], valid)
+		}
+		r, err := ctor(v[1:])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", v[0], err)
+		}
+		return r, nil
+	})
+}
+
 // readFile will read a file, strip comments, and collapse indents. This also
 // deals with the special "source" command.
 //
 // The return value is an nested slice where the first item is the original line
-// number and the second is the parsed line; for example:
+// number, the second is the parsed line, the third the original text of the
+// line (including any indented lines, separated by a newline), and the fourth
+// the file the line is in (which is different for "source" lines); for example:
 //
 //     [][]string{
-//         []string{3, "key value"},
-//         []string{9, "key2 value1 value2"},
+//         []string{3, "key value", "key   value  # comment", "config"},
+//         []string{9, "key2 value1 value2", "key2 value1\n    value2", "config"},
 //     }
 //
 // The line numbers can be used later to give more informative error messages.
 //
 // The input must be utf-8 encoded; other encodings are not supported.
 func readFile(file string) (lines [][]string, err error) {
+	return readFileWith(file, Options{})
+}
+
+func readFileWith(file string, opts Options) (lines [][]string, err error) {
+	return readFileChain(file, opts, nil)
+}
+
+// maxSourceDepth is the maximum number of nested "source" lines.
+const maxSourceDepth = 100
+
+// sourced is a file in the chain of "source" lines.
+type sourced struct {
+	path string // As it appears in the file, for errors.
+	abs  string // Absolute path with symlinks resolved, to detect loops.
+}
+
+// readFileChain reads a file; chain is the list of files that sourced it.
+func readFileChain(file string, opts Options, chain []sourced) (lines [][]string, err error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if r, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = r
+	}
+	for i, c := range chain {
+		if c.abs == abs {
+			names := make([]string, 0, len(chain)-i+1)
+			for _, c := range chain[i:] {
+				names = append(names, c.path)
+			}
+			return nil, fmt.Errorf("source loop detected: %s -> %s", strings.Join(names, " -> "), file)
+		}
+	}
+	if len(chain) > maxSourceDepth {
+		return nil, fmt.Errorf("source %s: too many nested source lines (max: %d)", file, maxSourceDepth)
+	}
+	chain = append(chain, sourced{path: file, abs: abs})
+
 	fp, err := os.Open(file)
 	if err != nil {
 		return lines, err
 	}
 	defer fp.Close()
+	return scan(fp, file, opts, chain)
+}
+
+// Line is a line from Scan().
+type Line struct {
+	No   int    // Line number.
+	Text string // Line with comments removed and whitespace collapsed.
+}
+
+// Scan reads the lines from r without setting anything: comments are removed,
+// whitespace is collapsed, indented lines are appended to the previous line,
+// and files from "source" lines are included. Relative paths for "source" are
+// relative to the directory of name.
+//
+// This is useful for tools that want to work with the contents of a config
+// file, such as linters or documentation generators.
+func Scan(r io.Reader, name string) ([]Line, error) {
+	var chain []sourced
+	if abs, err := filepath.Abs(name); err == nil {
+		chain = []sourced{{path: name, abs: abs}}
+	}
+	lines, err := scan(r, name, Options{}, chain)
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]Line, len(lines))
+	for i := range lines {
+		l[i].No, _ = strconv.Atoi(lines[i][0])
+		l[i].Text = lines[i][1]
+	}
+	return l, nil
+}
+
+// scan the lines from r; file is used to resolve "source" lines.
+func scan(r io.Reader, file string, opts Options, chain []sourced) (lines [][]string, err error) {
+	isComment := isComment
+	if opts.CommentChars != nil {
+		isComment = func(r rune) bool { return inRunes(r, opts.CommentChars) }
+	}
+	keyword := sourceKeyword(opts)
 
 	i := 0
 	no := 0
-	for scanner := bufio.NewScanner(fp); scanner.Scan(); {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
 		no++
 		line := scanner.Text()
+		raw := line
+		if opts.Normalize != nil {
+			line = opts.Normalize(line)
+		}
 
 		isIndented := len(line) > 0 && unicode.IsSpace(rune(line[0]))
 		line = strings.TrimSpace(line)
 
 		// Skip empty lines and comments
-		if line == "" || line[0] == '#' {
+		if line == "" || strings.IndexFunc(line, isComment) == 0 {
 			continue
 		}
 
-		line = collapseWhitespace(removeComments(line))
+		// "\source" is a regular key; the \ is removed by collapseWhitespace().
+		escaped := strings.HasPrefix(line, `\`)
+
+		line = collapseWhitespace(removeComments(line, isComment), opts.ExpandEnv)
+
+		isSource := keyword != "" && !isIndented && !escaped &&
+			(line == keyword || strings.HasPrefix(line, keyword+" "))
+		if isSource && strings.TrimSpace(line[len(keyword):]) == "" {
+			return nil, fmt.Errorf("%s line %d: %s: missing path", file, no, keyword)
+		}
 
 		switch {
 		// Regular line.
 		default:
-			lines = append(lines, []string{fmt.Sprintf("%d", no), line})
+			lines = append(lines, []string{fmt.Sprintf("%d", no), line, raw, file})
 			i++
 
 		// Indented.
@@ -97,25 +421,199 @@ func readFile(file string) (lines [][]string, err error) {
 			// Append to previous line; don't increment i since there may be
 			// more indented lines.
 			lines[i-1][1] += " " + strings.TrimSpace(line)
+			lines[i-1][2] += "\n" + raw
 
 		// Source command.
-		case strings.HasPrefix(line, "source "):
-			sourced, err := readFile(line[7:])
+		case isSource:
+			files, err := sourceFiles(file, strings.TrimSpace(line[len(keyword):]))
 			if err != nil {
 				return nil, err
 			}
-			lines = append(lines, sourced...)
-			i++
+			for _, f := range files {
+				sourcedLines, err := readFileChain(f, opts, chain)
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, sourcedLines...)
+			}
+			i = len(lines)
+		}
+	}
+
+	return lines, scanner.Err()
+}
+
+// sourceFiles gets the files for a "source" line in file. Relative paths are
+// relative to the directory of file. A path with any of the glob characters
+// "*?[" is expanded with filepath.Glob(), and it's an error if nothing matches.
+func sourceFiles(file, path string) ([]string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(file), path)
+	}
+	if !strings.ContainsAny(path, "*?[") {
+		return []string{path}, nil
+	}
+
+	files, err := filepath.Glob(path)
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %v", path, err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("source %s: no files match the pattern", path)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// checkVersion checks the value of the Options.VersionKey line, and removes it.
+func checkVersion(file string, lines [][]string, opts Options) ([][]string, error) {
+	if opts.VersionKey == "" {
+		return lines, nil
+	}
+
+	r := make([][]string, 0, len(lines))
+	for _, l := range lines {
+		v := strings.Split(l[1], " ")
+		if v[0] != opts.VersionKey {
+			r = append(r, l)
+			continue
+		}
+
+		if len(v) != 2 {
+			return nil, fmterr(lineFile(file, l), l[0], v[0], errValidateSingleValue)
+		}
+		n, err := strconv.Atoi(v[1])
+		if err != nil {
+			return nil, fmterr(lineFile(file, l), l[0], v[0], fmt.Errorf("invalid version %q", v[1]))
+		}
+		if !inIntList(n, opts.SupportedVersions) {
+			s := make([]string, len(opts.SupportedVersions))
+			for i := range opts.SupportedVersions {
+				s[i] = strconv.Itoa(opts.SupportedVersions[i])
+			}
+			return nil, fmterr(lineFile(file, l), l[0], v[0], fmt.Errorf(
+				"unsupported version %d (supported: %s)", n, strings.Join(s, ", ")))
+		}
+	}
+	return r, nil
+}
+
+func inIntList(n int, list []int) bool {
+	for _, l := range list {
+		if l == n {
+			return true
+		}
+	}
+	return false
+}
+
+// sectionLines gets all the lines in the section, removing the section headers.
+// An empty section gets all the lines before the first header.
+func sectionLines(lines [][]string, section string) ([][]string, error) {
+	var (
+		r       [][]string
+		current string
+		found   = section == ""
+	)
+	for _, l := range lines {
+		if name, ok := sectionHeader(l[1]); ok {
+			current = name
+			if current == section {
+				found = true
+			}
+			continue
+		}
+		if current == section {
+			r = append(r, l)
 		}
 	}
+	if !found {
+		return nil, fmt.Errorf("no section %q", section)
+	}
+	return r, nil
+}
+
+var errSectionHeader = errors.New("unexpected section header; use ParseSection() to parse a section")
+
+// sectionHeader reports if the line is a "[name]" section header.
+func sectionHeader(line string) (string, bool) {
+	if len(line) < 2 || line[0] != '[' || line[len(line)-1] != ']' {
+		return "", false
+	}
+	return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+// SourceKeyword is the key for lines that include another file, such as
+// "source other.conf". Includes are disabled if this is an empty string.
+//
+// Prefix the key with a backslash to use the keyword as a regular key, for
+// example "\source x" sets the Source field to "x".
+//
+// Use Options.SourceKeyword to set it for a single Parse() call.
+var SourceKeyword = "source"
+
+// sourceKeyword gets the SourceKeyword to use for opts.
+func sourceKeyword(opts Options) string {
+	switch opts.SourceKeyword {
+	case "":
+		return SourceKeyword
+	case "-":
+		return ""
+	}
+	return opts.SourceKeyword
+}
+
+// ClearKeyword is a value that empties a slice field before the other values
+// on the line are added, so a file can replace values set in an earlier
+// (sourced) file instead of appending to them:
+//
+//     str !clear        Set str to an empty slice.
+//     str !clear a b    Set str to just "a" and "b".
+//
+// This only applies if it's the first value, and not for fields with a
+// Handler. It's disabled if this is an empty string. Prefix it with a
+// backslash to use it as a regular value: "str \!clear" appends "!clear".
+//
+// Use Options.ClearKeyword to set it for a single Parse() call.
+var ClearKeyword = "!clear"
+
+// clearKeyword gets the ClearKeyword to use for opts.
+func clearKeyword(opts Options) string {
+	switch opts.ClearKeyword {
+	case "":
+		return ClearKeyword
+	case "-":
+		return ""
+	}
+	return opts.ClearKeyword
+}
+
+// CommentChars are the characters that start a comment. A comment character
+// can be escaped with a backslash to use it as a literal character, e.g. "\#".
+//
+// This applies to all files that are parsed, so it should be set only once
+// before parsing any files. For example to also allow ini-style comments:
+//
+//     sconfig.CommentChars = []rune{'#', ';'}
+//
+// Use Options.CommentChars to set it for a single Parse() call.
+var CommentChars = []rune{'#'}
+
+func isComment(r rune) bool { return inRunes(r, CommentChars) }
 
-	return lines, nil
+func inRunes(r rune, list []rune) bool {
+	for _, c := range list {
+		if r == c {
+			return true
+		}
+	}
+	return false
 }
 
-func removeComments(line string) string {
+func removeComments(line string, isComment func(rune) bool) string {
 	prevcmt := 0
 	for {
-		cmt := strings.Index(line[prevcmt:], "#")
+		cmt := strings.IndexFunc(line[prevcmt:], isComment)
 		if cmt < 0 {
 			break
 		}
@@ -124,7 +622,7 @@ func removeComments(line string) string {
 		prevcmt = cmt
 
 		// Allow escaping # with \#
-		if line[cmt-1] == '\\' {
+		if cmt > 0 && line[cmt-1] == '\\' {
 			line = line[:cmt-1] + line[cmt:]
 		} else {
 			// Found comment, remove the comment text and trailing whitespace.
@@ -136,14 +634,19 @@ func removeComments(line string) string {
 	return line
 }
 
-func collapseWhitespace(line string) string {
+// collapseWhitespace collapses all whitespace to a single space, and removes
+// the backslash from escaped characters. If keepDollar is set "\$" is kept as-is
+// so the escape is still visible when expanding environment variables.
+func collapseWhitespace(line string, keepDollar bool) string {
 	nl := ""
 	prevSpace := false
 	for i, char := range line {
 		switch {
 		case char == '\\':
 			// \ is escaped with \: "\\"
-			if line[i-1] == '\\' {
+			if i > 0 && line[i-1] == '\\' {
+				nl += `\`
+			} else if keepDollar && i < len(line)-1 && line[i+1] == '$' {
 				nl += `\`
 			}
 		case unicode.IsSpace(char):
@@ -186,13 +689,119 @@ var dontPanic = true
 // example "key-name" becomes "KeyName". You can also use the plural
 // ("KeyNames") as the field name.
 //
+// The name in the "sconfig" struct tag is used if a field has one; this takes
+// precedence over the inferred name, so with:
+//
+//     Endpoint string `sconfig:"api-base"`
+//     APIBase  string
+//
+// The key "api-base" sets Endpoint rather than APIBase.
+//
+// Slice fields are appended to for every line, so "host a" and "host b" on two
+// lines will set a "Hosts []string" field to []string{"a", "b"}.
+//
+// The lines of a file included with "source" are inserted where the "source"
+// line is, so slices are always in the same order as the lines they're on:
+//
+//     host a
+//     source other-file    # Contains "host b" and "host c"
+//     host d
+//
+// Will set Hosts to []string{"a", "b", "c", "d"}.
+//
+// A relative path for "source" is relative to the directory of the file it's
+// in, rather than the current working directory.
+//
+// The path for "source" can be a glob pattern such as "conf.d/*.conf" (see
+// filepath.Match() for the syntax); all matching files are included in sorted
+// order, and it's an error if no files match. A file that (indirectly)
+// sources itself is an error, as is nesting "source" more than 100 levels deep.
+//
+// A slice of slices such as [][]string or [][]int64 gets a new row for every
+// line instead, with the values parsed by the type handler for the inner slice:
+//
+//     row a b c
+//     row d e
+//
+// Will set Row to [][]string{{"a", "b", "c"}, {"d", "e"}}.
+//
+// Map fields are added to for every line; for a map[string]string the values
+// are key/value pairs, and for a map[string][]string the first value is the key
+// and the rest are appended to the slice for that key:
+//
+//     header Content-Type text/html
+//     header X-Frame-Options deny
+//
+// Pointer fields such as *int64 or []*string use the type handler of the type
+// they point to; a nil pointer is allocated when the field is set, and an
+// existing pointer is re-used.
+//
+// A dotted key sets a field in a nested struct; "tls.cert" sets the Cert field
+// of a TLS struct field. If TLS is a pointer to a struct it's allocated when
+// it's first used, so it will be nil if there are no "tls.*" keys. Handlers
+// and Options.AllowFields use the full path (e.g. "TLS.Cert").
+//
+// If a field in a dotted key is a map then the rest of the key is used as the
+// map key: "cache.5m 500" sets the key 5*time.Minute in a
+// map[time.Duration]int64 field. The map key can be of any type with a type
+// handler or an encoding.TextUnmarshaler implementation, and it's an error if
+// it can't be parsed.
+//
+// Map values are set like any other field, so slice values are appended to when
+// a map key is repeated:
+//
+//     header.accept json
+//     header.accept xml
+//
+// Sets Header to map[string][]string{"accept": {"json", "xml"}}.
+//
+// Fields with the "required" option in the struct tag must be in the file, and
+// a *RequiredError with all missing fields is returned if they're not:
+//
+//     Port int64 `sconfig:",required"`
+//
+// Default values can be set in the "default" struct tag; this is used for
+// fields that aren't in the file:
+//
+//     Port int64 `default:"8080"`
+//
+// The value is split on whitespace and set like a value from the file. It's
+// only used if the field is still the zero value, so fields that are set
+// before calling Parse() are kept. When parsing several files in to the same
+// struct a value set to the zero value in an earlier file (e.g. "enabled
+// false") can't be distinguished from an unset field; use SetDefaults() and
+// Options.NoDefaults for this, as in the FindConfigAll() example.
+//
 // sconfig will attempt to set the field from the passed Handlers map (see
-// below), a configured type handler, or the encoding.TextUnmarshaler interface,
-// in that order.
+// below), Options.FieldHandlers, a configured type handler, the
+// encoding.TextUnmarshaler interface, a "Set(string) error" method (such as
+// flag.Value), or the json.Unmarshaler interface, in that order. The first one
+// that exists is used; for example a type that implements both
+// encoding.TextUnmarshaler and json.Unmarshaler is always set with
+// UnmarshalText(). Slices of a type that implements encoding.TextUnmarshaler
+// are set by unmarshaling every value in to a new element.
+//
+// For json.Unmarshaler the value is passed as-is, so it must be valid JSON;
+// for example a string must be quoted:
+//
+//     name "Martin"
+//     point {"x": 1, "y": 2}
+//
+// Fields that are a slice of structs get one element for every line, where
+// every value sets the next exported field; for example with:
+//
+//     Servers []struct {
+//         Name string
+//         Port int64
+//     }
+//
+// The lines "server web1 8080" and "server web2 8081" add two servers. It's an
+// error if the number of values isn't the same as the number of fields.
 //
 // The Handlers map, which may be nil, can be given to customize the behaviour
 // for individual configuration keys. This will override the type handler (if
-// any). The function is expected to set any settings on the struct; for
+// any), including any validators; see Options.ValidateHandlers to run the
+// validators first. The function is expected to set any settings on the struct; for
 // example:
 //
 //  Parse(&config, "config", Handlers{
@@ -207,181 +816,1222 @@ var dontPanic = true
 // Will allow you to do:
 //
 //   special-bool yup!
-func Parse(config interface{}, file string, handlers Handlers) (returnErr error) {
-	// Recover from panics; return them as errors!
-	// TODO: This loses the stack though...
-	defer func() {
-		if dontPanic {
-			if rec := recover(); rec != nil {
-				switch recType := rec.(type) {
-				case error:
-					returnErr = recType
-				default:
-					panic(rec)
-				}
-			}
-		}
-	}()
-
-	lines, err := readFile(file)
-	if err != nil {
-		return err
-	}
+func Parse(config interface{}, file string, handlers Handlers) error {
+	return ParseWith(config, file, Options{Handlers: handlers})
+}
 
-	values := getValues(config)
+// Options for ParseWith().
+type Options struct {
+	// Handlers to use for fields; see Parse().
+	Handlers Handlers
 
-	// Get list of rule names from tags
-	for _, line := range lines {
-		// Split by spaces
-		v := strings.Split(line[1], " ")
+	// HandlersCtx are like Handlers, but the functions also get the file,
+	// line number, and key. A Handler for the same field takes precedence.
+	HandlersCtx map[string]HandlerCtx
+	handlerCtx  HandlerContext
 
-		var (
-			field     reflect.Value
-			fieldName string
-		)
-		switch values.Kind() {
+	// ValidateHandlers runs the validators for the field's type before running
+	// a Handler. By default the type handlers are skipped entirely if there's a
+	// Handler for a field, including any validators.
+	//
+	// The validators are all but the last function registered with
+	// RegisterType(); for example with:
+	//
+	//     RegisterType("int64", ValidateSingleValue(), HandleInt64)
+	//
+	// ValidateSingleValue() is run before the Handler, and the Handler is only
+	// run if it doesn't return an error.
+	ValidateHandlers bool
 
-		// TODO: Only support map[string][]string atm.
-		case reflect.Map:
-			fieldName = v[0]
-			mapKey := reflect.ValueOf(v[0]).Convert(reflect.TypeOf(fieldName))
-			values.SetMapIndex(mapKey, reflect.ValueOf(v[1:]))
+	// FieldHandlers are type handlers for individual fields, which are used
+	// instead of the type handler for the field's type. The map key is the
+	// field name, as with Handlers. For example to lowercase one string field:
+	//
+	//     FieldHandlers: map[string]sconfig.TypeHandler{
+	//         "Hostname": func(v []string) (interface{}, error) {
+	//             return strings.ToLower(strings.Join(v, " ")), nil
+	//         },
+	//     }
+	//
+	// Unlike a Handler this returns the value, which is set on the field like
+	// any other value from a type handler (e.g. slices are appended to).
+	FieldHandlers map[string]TypeHandler
 
-			continue
+	// StrictTags disables inferring the field name from the key; every key
+	// must be identical to the name in the "sconfig" struct tag of a field:
+	//
+	//     BaseURL string `sconfig:"base-url"`
+	//
+	// Keys such as "BaseURL", "base-urls", or "base_url" are all errors, as
+	// are keys for fields without a name in the tag.
+	StrictTags bool
 
-		case reflect.Struct:
-			// Infer the field name from the key
-			var err error
-			fieldName, err = fieldNameFromKey(v[0], values)
-			if err != nil {
-				return fmterr(file, line[0], v[0], err)
-			}
-			field = values.FieldByName(fieldName)
+	// Strict requires that the key is the exact field name after converting
+	// it to CamelCase ("base-url" is "BaseUrl"), or the name in the struct
+	// tag. By default the plural or singular form of the name is also
+	// accepted ("host" for the Hosts field), as are common acronyms in upper
+	// case ("base-url" for the BaseURL field).
+	Strict bool
 
-		default:
-			return fmt.Errorf("unknown type: %v", values.Kind())
-		}
+	// FoldKeys matches keys with field names ignoring case, "-", and "_", so
+	// that "base-url", "BASE-URL", "BaseUrl", and "baseurl" all set the BaseURL
+	// field. This is only used if the key doesn't match a field in the usual
+	// way, and it's an error if more than one field matches.
+	FoldKeys bool
 
-		// Use the handler if it exists.
-		if has, err := setFromHandler(fieldName, v[1:], handlers); has {
-			if err != nil {
-				return fmterr(file, line[0], v[0], err)
-			}
-			continue
-		}
+	// CommentChars are the characters that start a comment; the package-level
+	// CommentChars is used if this is nil.
+	CommentChars []rune
 
-		// Set from type handler.
-		if has, err := setFromTypeHandler(&field, v[1:]); has {
-			if err != nil {
-				return fmterr(file, line[0], v[0], err)
-			}
-			continue
-		}
+	// SourceKeyword is the key for lines that include another file; the
+	// package-level SourceKeyword is used if this is empty. Use "-" to disable
+	// includes.
+	SourceKeyword string
 
-		// Set from encoding.TextUnmarshaler.
-		if m, ok := field.Interface().(encoding.TextUnmarshaler); ok {
-			if field.IsNil() {
-				field.Set(reflect.New(field.Type().Elem()))
-				m = field.Interface().(encoding.TextUnmarshaler)
-			}
+	// ClearKeyword is the value that empties a slice field; the package-level
+	// ClearKeyword is used if this is empty. Use "-" to disable it.
+	ClearKeyword string
 
-			err := m.UnmarshalText([]byte(strings.Join(v[1:], " ")))
-			if err != nil {
-				return fmterr(file, line[0], v[0], err)
-			}
-			continue
-		}
+	// IgnoreUnknown skips keys that don't match a field, instead of returning
+	// an error. The keys are appended to Unknown if it's not nil, so they can
+	// be logged:
+	//
+	//     var unknown []string
+	//     err := sconfig.ParseWith(&c, "config", sconfig.Options{
+	//         IgnoreUnknown: true,
+	//         Unknown:       &unknown,
+	//     })
+	//
+	// Values that can't be set on a known field are still an error.
+	IgnoreUnknown bool
+	Unknown       *[]string
 
-		// Give up :-(
-		return fmterr(file, line[0], v[0], fmt.Errorf(
-			"don't know how to set fields of the type %s",
-			field.Type().String()))
-	}
+	// Unused is like Unknown, but gets the full lines with the line numbers
+	// for all keys that were skipped with IgnoreUnknown. This is useful to
+	// report lines with deprecated keys, for example.
+	Unused *[]Line
 
-	return returnErr // Can be set by defer
-}
+	// AllowFields is a list of field names that can be set; keys which resolve
+	// to any other field are an error. All fields are allowed if this is nil.
+	//
+	// Fields in nested structs use the full path (e.g. "TLS.Cert"); allowing
+	// a struct field (e.g. "TLS") allows all of its fields. Map entries work
+	// the same: "Headers" allows all keys, and "Headers.accept" just one.
+	//
+	// This is useful if you want to parse a partially trusted file in to a
+	// larger struct.
+	AllowFields []string
 
-// Fields gets a list of all fields in a struct. The map key is the name of the
-// field (as it appears in the struct) and the key is the field's reflect.Value
-// (which can be used to set a value).
-//
-// This is useful if you want to batch operate on a config struct, for example
-// to override from the environment or flags.
-func Fields(config interface{}) map[string]reflect.Value {
-	r := make(map[string]reflect.Value)
-	v := reflect.ValueOf(config).Elem()
-	t := reflect.TypeOf(config).Elem()
-	for i := 0; i < v.NumField(); i++ {
-		r[t.Field(i).Name] = v.Field(i)
-	}
+	// DenyFields is a list of field names that can't be set. Keys which
+	// resolve to any of these fields are an error. Denying a struct or map
+	// field (e.g. "TLS") also denies all of its fields or keys.
+	DenyFields []string
 
-	return r
-}
+	// ExpandEnv expands environment variables in values:
+	//
+	//     ${VAR}              Value of $VAR, or an empty string if it's unset.
+	//     $VAR                Same as ${VAR}; the name ends at the first
+	//                         character that's not a letter, digit, or "_".
+	//     ${VAR ?? default}   Value of $VAR, or "default" if it's unset or empty.
+	//     \$                  A literal "$".
+	//
+	// A "$" that's not followed by "{" or a letter or "_" (e.g. "$5") is kept
+	// as-is.
+	//
+	// The default can contain spaces and other ${..} expressions, which are
+	// only expanded if they're used.
+	//
+	// Every ${..} expands to exactly one value; spaces in the environment
+	// variable (or default) don't split it in to several values.
+	ExpandEnv bool
+
+	// RequireEnv makes it an error if a variable for ExpandEnv isn't set,
+	// instead of expanding it to an empty string. Variables with a default
+	// (${VAR ?? default}) can still be unset.
+	RequireEnv bool
+
+	// AllowExec enables command substitution in values: `cmd` is replaced by
+	// the output of cmd with leading and trailing whitespace removed. It's an
+	// error if the command exits with a non-zero status.
+	//
+	// This runs arbitrary commands from the config file! Only enable it if you
+	// trust the file as much as you would trust a shell script.
+	//
+	// Like ${..}, every `..` expands to exactly one value. There is no way to
+	// use a literal ` in values if this is enabled.
+	AllowExec bool
+
+	// ExecShell is the command used to run `..` commands with AllowExec; the
+	// command is added as the last argument. The default is "/bin/sh -c".
+	//
+	// If this is set to an empty (non-nil) slice the command is split on
+	// whitespace and run directly, without a shell.
+	ExecShell []string
+
+	// SliceSep splits the values for slice fields on this separator instead of
+	// whitespace; for example with "," the line "hosts a, b,c" will set a
+	// []string field to []string{"a", "b", "c"}. Whitespace around the values
+	// is removed, and empty values are skipped.
+	//
+	// A "sep" option in the field's struct tag takes precedence over this:
+	//
+	//     Paths []string `sconfig:"sep=:"`
+	//
+	// This doesn't affect Handlers, which always get the values split on
+	// whitespace.
+	SliceSep string
+
+	// RawLines is filled with the original text of the lines for every field
+	// that's set, with the field name as the key. The map needs to be
+	// allocated by the caller; nothing is recorded if it's nil.
+	//
+	// If a field is set more than once (e.g. slices) the last line is stored.
+	RawLines map[string]RawLine
+
+	// Provenance is filled with where the value of every field came from,
+	// with the field name as the key; fields in nested structs use a dotted
+	// path, as with FieldsNested(). The map needs to be allocated by the
+	// caller; nothing is recorded if it's nil.
+	//
+	// If a field is set more than once (e.g. slices) the last line is stored.
+	// Fields that weren't set have the kind SourceUnset.
+	Provenance map[string]Source
+
+	// VersionKey is the key for the config file version, such as
+	// "config-version". If this key appears in the file its value must be one
+	// of SupportedVersions, and it's an error otherwise. The version is
+	// checked before anything else is parsed, and doesn't need a struct
+	// field.
+	//
+	// Files without the version key are always accepted.
+	VersionKey        string
+	SupportedVersions []int
+
+	// Section to parse; only lines after a "[section]" header (until the next
+	// header) are used, and lines that are not in any section (before the
+	// first header) are ignored.
+	//
+	// It's an error if the section doesn't exist in the file. It's also an
+	// error if this is empty and the file has a section header; use
+	// ParseSection() with an empty section to get the lines before the first
+	// header.
+	Section string
+
+	// Normalize is called for every line before it's processed, if set.
+	//
+	// This can be used to normalize Unicode text, which is not done by
+	// default. For example files edited on macOS may use the NFD form, while
+	// Go field names and most strings are in the NFC form; to normalize all
+	// text to NFC with the golang.org/x/text/unicode/norm package:
+	//
+	//     sconfig.ParseWith(&c, "config", sconfig.Options{
+	//         Normalize: norm.NFC.String,
+	//     })
+	Normalize func(string) string
+
+	// Intern strings for string and []string fields, so that identical
+	// strings share the same memory.
+	//
+	// This uses a map to look up every string, which makes parsing slower but
+	// can save a lot of memory if there are many duplicate strings, such as
+	// large lists with the same values.
+	Intern   bool
+	interned map[string]string
+
+	// NoDefaults doesn't set values from the "default" struct tag; see
+	// SetDefaults().
+	NoDefaults bool
+
+	// ContinueOnError continues parsing after an error, instead of stopping at
+	// the first one. All errors are returned as a *MultiError. Lines with an
+	// error don't change the field; defaults are still set and required fields
+	// are still checked, and a *RequiredError is added to the list.
+	ContinueOnError bool
+
+	// MaxErrors is the maximum number of errors to collect with
+	// ContinueOnError; any further errors are only counted, and reported as
+	// "and N more errors". There is no limit if this is 0.
+	MaxErrors int
+
+	// Context to use; parsing is stopped if it's cancelled, and the context's
+	// error is returned. The context is checked before every line, so a
+	// handler that's already running can't be interrupted unless it also uses
+	// the context.
+	Context context.Context
+
+	setFields map[string]bool                    // Fields set from the file, for setDefaults().
+	tagNames  map[reflect.Type]map[string]string // Cache for tagName().
+	sections  bool                               // Parse sections even if Section is empty.
+}
+
+// RawLine is the original text of a line in the config file.
+type RawLine struct {
+	Field string // Name of the struct field or map key.
+	Line  int    // Line number.
+
+	// Text as it appears in the file, including comments and whitespace.
+	// Indented lines are separated by a newline.
+	Text string
+}
+
+// Source is where the value of a field came from; see Options.Provenance.
+type Source struct {
+	File string // File and line number for SourceFile and SourceHandler.
+	Line int
+	Kind SourceKind
+}
+
+// SourceKind is the kind of Source.
+type SourceKind uint8
+
+// Kinds for Source.
+const (
+	SourceUnset   SourceKind = iota // Not set; the value is unchanged.
+	SourceDefault                   // From the "default" struct tag.
+	SourceFile                      // From a line in the file.
+	SourceHandler                   // From a line in the file, with a Handler.
+)
+
+func (k SourceKind) String() string {
+	switch k {
+	case SourceUnset:
+		return "unset"
+	case SourceDefault:
+		return "default"
+	case SourceFile:
+		return "file"
+	case SourceHandler:
+		return "handler"
+	}
+	return fmt.Sprintf("SourceKind(%d)", k)
+}
+
+// ParseTimeout is like Parse(), but stops with an error wrapping
+// context.DeadlineExceeded if parsing takes longer than the timeout.
+//
+// Handlers can't be interrupted while running; use ParseWith() with a
+// Options.Context and use the same context in your handlers for that.
+func ParseTimeout(config interface{}, file string, handlers Handlers, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return ParseWith(config, file, Options{Handlers: handlers, Context: ctx})
+}
+
+// ParseSection is like Parse(), but only parses the lines in the named
+// section; for example with:
+//
+//     port 8080
+//
+//     [db]
+//     host localhost
+//     port 5432
+//
+// ParseSection(&c, "config", "db", nil) will only set Host and Port from the
+// "db" section. Use an empty section to get the lines before the first section
+// header; Parse() returns an error for files with section headers.
+//
+// A "[name]" header can appear more than once; all the lines are used.
+func ParseSection(config interface{}, file, section string, handlers Handlers) error {
+	return ParseWith(config, file, Options{Handlers: handlers, Section: section, sections: true})
+}
+
+// ParseWith is like Parse(), but with more options. Parse() is the same as
+// ParseWith() with only Options.Handlers set.
+func ParseWith(config interface{}, file string, opts Options) (returnErr error) {
+	return parseFile([]interface{}{config}, file, opts)
+}
+
+// ParseMulti is like Parse(), but sets the fields in several structs while
+// reading the file only once. This is useful if several parts of an
+// application each have their own config struct.
+//
+// Every key is set on the first target that has a field for it, so if two
+// targets have the same field only the first one is set. It's an error if none
+// of the targets have a field for a key.
+func ParseMulti(file string, handlers Handlers, targets ...interface{}) error {
+	if len(targets) == 0 {
+		return errors.New("sconfig.ParseMulti: no targets")
+	}
+	return parseFile(targets, file, Options{Handlers: handlers})
+}
+
+// ParseAll is like Parse(), but doesn't stop at the first error. All errors
+// are returned as a *MultiError, and lines with an error don't change the
+// field.
+//
+// This is the same as ParseWith() with Options.ContinueOnError.
+func ParseAll(config interface{}, file string, handlers Handlers) error {
+	return ParseWith(config, file, Options{Handlers: handlers, ContinueOnError: true})
+}
+
+func parseFile(configs []interface{}, file string, opts Options) (returnErr error) {
+	// Recover from panics; return them as errors!
+	// TODO: This loses the stack though...
+	defer func() {
+		if dontPanic {
+			if rec := recover(); rec != nil {
+				switch recType := rec.(type) {
+				case error:
+					returnErr = recType
+				default:
+					panic(rec)
+				}
+			}
+		}
+	}()
+
+	if opts.Intern {
+		opts.interned = make(map[string]string)
+	}
+	opts.setFields = make(map[string]bool)
+	opts.tagNames = make(map[reflect.Type]map[string]string)
+
+	lines, err := readFileWith(file, opts)
+	if err != nil {
+		return err
+	}
+	lines, err = checkVersion(file, lines, opts)
+	if err != nil {
+		return err
+	}
+	if opts.Section != "" || opts.sections {
+		lines, err = sectionLines(lines, opts.Section)
+		if err != nil {
+			return fmt.Errorf("%v: %v", file, err)
+		}
+	} else {
+		for _, l := range lines {
+			if _, ok := sectionHeader(l[1]); ok {
+				return fmterr(lineFile(file, l), l[0], l[1], errSectionHeader)
+			}
+		}
+	}
+
+	targets := make([]reflect.Value, len(configs))
+	for i := range configs {
+		targets[i] = getValues(configs[i])
+	}
+
+	// With ContinueOnError the defaults and required fields are still
+	// processed, and any errors are added to the list.
+	var errs *MultiError
+	err = parseLines(targets, file, lines, opts)
+	if err != nil {
+		var ok bool
+		errs, ok = err.(*MultiError)
+		if !ok {
+			return err
+		}
+	}
+
+	var missing []string
+	for _, t := range targets {
+		if t.Kind() == reflect.Struct {
+			missing = append(missing, missingFields(t, "", opts)...)
+			if !opts.NoDefaults {
+				if err := setDefaults(t, "", opts); err != nil {
+					err = fmt.Errorf("%v: %v", file, err)
+					if errs == nil {
+						return err
+					}
+					errs.add(err, opts.MaxErrors)
+				}
+			}
+			if opts.Provenance != nil {
+				recordUnset(t, opts)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		err := &RequiredError{File: file, Fields: missing}
+		if errs == nil {
+			return err
+		}
+		errs.add(err, opts.MaxErrors)
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// RequiredError is returned if fields with the "required" option in the struct
+// tag aren't set in the file.
+type RequiredError struct {
+	File   string
+	Fields []string // Field names, e.g. "Port" or "TLS.Cert".
+}
+
+func (e *RequiredError) Error() string {
+	return fmt.Sprintf("%v: missing required options: %s", e.File, strings.Join(e.Fields, ", "))
+}
+
+// missingFields gets all fields with the "required" option that weren't set.
+func missingFields(values reflect.Value, prefix string, opts Options) []string {
+	var missing []string
+	t := values.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" { // Unexported
+			continue
+		}
+		name := prefix + sf.Name
+		if parseTag(sf.Tag).Has("required") {
+			if !opts.setFields[name] {
+				missing = append(missing, name)
+			}
+			continue
+		}
+
+		field := values.Field(i)
+		if field.Kind() == reflect.Ptr && !field.IsNil() {
+			field = field.Elem()
+		}
+		if field.Kind() == reflect.Struct {
+			missing = append(missing, missingFields(field, name+".", opts)...)
+		}
+	}
+	return missing
+}
+
+// setDefaults sets the value from the "default" struct tag for all fields that
+// weren't set from the file and still have the zero value.
+func setDefaults(values reflect.Value, prefix string, opts Options) error {
+	t := values.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" { // Unexported
+			continue
+		}
+		field := values.Field(i)
+		name := prefix + sf.Name
+
+		def, ok := sf.Tag.Lookup("default")
+		if !ok {
+			if field.Kind() == reflect.Ptr && !field.IsNil() {
+				field = field.Elem()
+			}
+			if field.Kind() == reflect.Struct {
+				if err := setDefaults(field, name+".", opts); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if opts.setFields[name] || !field.IsZero() {
+			continue
+		}
+
+		err := setField(field, name, parseTag(sf.Tag), strings.Fields(def), Options{})
+		if err != nil {
+			return fmt.Errorf("invalid default %q for %s: %v", def, name, err)
+		}
+		if opts.Provenance != nil {
+			opts.Provenance[name] = Source{Kind: SourceDefault}
+		}
+	}
+	return nil
+}
+
+// SetDefaults sets the value from the "default" struct tag for all fields that
+// have the zero value.
+//
+// This is done by Parse() already; it's only useful to set the defaults once
+// before parsing several files with Options.NoDefaults.
+func SetDefaults(config interface{}) error {
+	v := reflect.ValueOf(config)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("sconfig.SetDefaults: must be a pointer to a struct, not %T", config)
+	}
+	if err := setDefaults(v.Elem(), "", Options{}); err != nil {
+		return fmt.Errorf("sconfig.SetDefaults: %v", err)
+	}
+	return nil
+}
+
+// parseLines sets all the lines in the targets.
+func parseLines(targets []reflect.Value, file string, lines [][]string, opts Options) error {
+	var errs *MultiError
+	for _, line := range lines {
+		if opts.Context != nil && opts.Context.Err() != nil {
+			return fmt.Errorf("%v line %v: %w", lineFile(file, line), line[0], opts.Context.Err())
+		}
+
+		err := parseLine(targets, file, line, opts)
+		if err == nil {
+			continue
+		}
+		if !opts.ContinueOnError {
+			return err
+		}
+		if errs == nil {
+			errs = &MultiError{}
+		}
+		errs.add(err, opts.MaxErrors)
+	}
+	if errs != nil {
+		return errs
+	}
+	return nil
+}
+
+// parseLine sets the field for a single line.
+func parseLine(targets []reflect.Value, file string, line []string, opts Options) error {
+	file = lineFile(file, line)
+	v, err := splitLine(line[1], opts)
+	if err != nil {
+		return fmterr(file, line[0], v[0], err)
+	}
+
+	var (
+		field     reflect.Value
+		fieldName string
+		tag       Tag
+		commit    func()
+	)
+	values := targets[0]
+	switch values.Kind() {
+
+	// TODO: Only support map[string][]string atm.
+	case reflect.Map:
+		fieldName = v[0]
+		recordRaw(fieldName, line, opts)
+		mapKey := reflect.ValueOf(v[0]).Convert(reflect.TypeOf(fieldName))
+		values.SetMapIndex(mapKey, reflect.ValueOf(v[1:]))
+		recordSource(fieldName, file, line, opts)
+		return nil
+
+	case reflect.Struct:
+		// Infer the field name from the key
+		var sf reflect.StructField
+		field, sf, fieldName, commit, err = resolveField(v[0], values, opts)
+		for _, t := range targets[1:] {
+			if err == nil {
+				break
+			}
+			field, sf, fieldName, commit, err = resolveField(v[0], t, opts)
+		}
+		if _, ok := err.(*UnknownOptionError); ok && opts.IgnoreUnknown {
+			if opts.Unknown != nil {
+				*opts.Unknown = append(*opts.Unknown, v[0])
+			}
+			if opts.Unused != nil {
+				no, _ := strconv.Atoi(line[0])
+				*opts.Unused = append(*opts.Unused, Line{No: no, Text: line[1]})
+			}
+			return nil
+		}
+		if err != nil {
+			return fmterr(file, line[0], v[0], err)
+		}
+		tag = parseTag(sf.Tag)
+
+		if err := allowField(fieldName, opts); err != nil {
+			return fmterr(file, line[0], v[0], err)
+		}
+		recordRaw(fieldName, line, opts)
+
+	default:
+		return fmt.Errorf("unknown type: %v", values.Kind())
+	}
+
+	// Replaced and cleared slices are set on a new value which is only
+	// assigned to the field if there are no errors, so a line with an error
+	// doesn't change it.
+	target := field
+	clear := isClear(field, fieldName, v, line, opts)
+	replace := clear || (tag.Has("replace") && field.Kind() == reflect.Slice &&
+		opts.Handlers[fieldName] == nil && opts.HandlersCtx[fieldName] == nil)
+	if replace {
+		field = reflect.New(field.Type()).Elem()
+	}
+	if clear {
+		field.Set(reflect.MakeSlice(field.Type(), 0, 0))
+		v = append(v[:1], v[2:]...)
+		if len(v) == 1 {
+			target.Set(field)
+			if opts.setFields != nil {
+				opts.setFields[fieldName] = true
+			}
+			recordSource(fieldName, file, line, opts)
+			if commit != nil {
+				commit()
+			}
+			return nil
+		}
+	}
+
+	if opts.HandlersCtx != nil {
+		no, _ := strconv.Atoi(line[0])
+		opts.handlerCtx = HandlerContext{File: file, Line: no, Key: v[0]}
+	}
+	if err := setField(field, fieldName, tag, v[1:], opts); err != nil {
+		return fmterr(file, line[0], v[0], err)
+	}
+	if replace {
+		target.Set(field)
+	}
+	if opts.setFields != nil {
+		opts.setFields[fieldName] = true
+	}
+	recordSource(fieldName, file, line, opts)
+	if commit != nil {
+		commit()
+	}
+	return nil
+}
+
+// MultiError is returned by ParseAll(), or ParseWith() if
+// Options.ContinueOnError is set, and there are one or more errors.
+type MultiError struct {
+	errs []error
+	more int
+}
+
+// add an error, or only count it if there are already max errors.
+func (e *MultiError) add(err error, max int) {
+	if max > 0 && len(e.errs) >= max {
+		e.more++
+		return
+	}
+	e.errs = append(e.errs, err)
+}
+
+// Errors gets all the errors, in the order they occurred. This doesn't include
+// errors after Options.MaxErrors.
+func (e *MultiError) Errors() []error { return e.errs }
+
+// Error lists all the errors, one per line. If there were more errors than
+// Options.MaxErrors the last line is "and N more errors".
+func (e *MultiError) Error() string {
+	msg := make([]string, 0, len(e.errs)+1)
+	for _, err := range e.errs {
+		msg = append(msg, err.Error())
+	}
+	switch {
+	case e.more == 1:
+		msg = append(msg, "and 1 more error")
+	case e.more > 1:
+		msg = append(msg, fmt.Sprintf("and %d more errors", e.more))
+	}
+	return strings.Join(msg, "\n")
+}
+
+// setField sets the field from a Handler, type handler,
+// encoding.TextUnmarshaler (also for every element of slices), Set() method,
+// json.Unmarshaler, or as an element of a slice of structs.
+func setField(field reflect.Value, fieldName string, tag Tag, values []string, opts Options) error {
+	// Use the handler if it exists.
+	if opts.ValidateHandlers && (opts.Handlers[fieldName] != nil || opts.HandlersCtx[fieldName] != nil) {
+		if err := runValidators(field, values); err != nil {
+			return err
+		}
+	}
+	if has, err := setFromHandler(fieldName, values, opts); has {
+		return err
+	}
+
+	// Increment counters.
+	if tag.Has("count") && len(values) == 0 {
+		return increment(field)
+	}
+
+	// Set from type handler.
+	prevLen := 0
+	if field.Kind() == reflect.Slice {
+		prevLen = field.Len()
+	}
+	if h, ok := opts.FieldHandlers[fieldName]; ok {
+		v, err := h(values)
+		if err != nil {
+			return err
+		}
+		return setTypeHandlerValue(&field, tag, v)
+	}
+	if has, err := setFromTypeHandler(&field, tag, trim(field, tag, splitSep(field, tag, values, opts))); has {
+		if err == nil && opts.interned != nil {
+			intern(field, prevLen, opts.interned)
+		}
+		return err
+	}
+
+	// Set from encoding.TextUnmarshaler.
+	ptr := field
+	if ptr.Kind() != reflect.Ptr && ptr.CanAddr() {
+		ptr = ptr.Addr()
+	}
+	if m, ok := ptr.Interface().(encoding.TextUnmarshaler); ok {
+		if ptr.IsNil() {
+			ptr.Set(reflect.New(ptr.Type().Elem()))
+			m = ptr.Interface().(encoding.TextUnmarshaler)
+		}
+		return m.UnmarshalText([]byte(strings.Join(values, " ")))
+	}
+	if has, err := setTextSlice(field, tag, trim(field, tag, splitSep(field, tag, values, opts))); has {
+		return err
+	}
+
+	// Set from Set(string) error, e.g. flag.Value.
+	if has, err := setFromSetter(field, values); has {
+		return err
+	}
+
+	// Set from json.Unmarshaler.
+	if has, err := setFromJSON(field, values); has {
+		return err
+	}
+
+	// Set slices of structs, one element for every line.
+	if has, err := setStructSlice(field, values); has {
+		return err
+	}
+
+	// Give up :-(
+	return fmt.Errorf("don't know how to set fields of the type %s", field.Type().String())
+}
+
+// Fields gets a list of all fields in a struct. The map key is the name of the
+// field (as it appears in the struct) and the key is the field's reflect.Value
+// (which can be used to set a value).
+//
+// This is useful if you want to batch operate on a config struct, for example
+// to override from the environment or flags.
+func Fields(config interface{}) map[string]reflect.Value {
+	r := make(map[string]reflect.Value)
+	v := reflect.ValueOf(config).Elem()
+	t := reflect.TypeOf(config).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		r[t.Field(i).Name] = v.Field(i)
+	}
+
+	return r
+}
+
+// FieldsNested is like Fields(), but also gets the fields in nested structs
+// (and pointers to structs), with a dotted path as the key: a Server struct
+// with a Port field is "Server.Port". Unexported fields are skipped.
+//
+// Only the fields in the nested structs are added, not the structs themselves.
+// Nil pointers aren't followed and are added as-is, as are structs with a type
+// handler or the encoding.TextUnmarshaler interface, such as time.Time.
+func FieldsNested(config interface{}) map[string]reflect.Value {
+	r := make(map[string]reflect.Value)
+	fieldsNested(r, "", reflect.ValueOf(config).Elem())
+	return r
+}
+
+func fieldsNested(r map[string]reflect.Value, prefix string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		if t.Field(i).PkgPath != "" { // Unexported
+			continue
+		}
+		name := prefix + t.Field(i).Name
+		f := v.Field(i)
+
+		s := f
+		if s.Kind() == reflect.Ptr && !s.IsNil() {
+			s = s.Elem()
+		}
+		if s.Kind() == reflect.Struct && !isValue(s.Type()) {
+			fieldsNested(r, name+".", s)
+			continue
+		}
+		r[name] = f
+	}
+}
+
+// isValue reports if a struct type is set as a single value, rather than as
+// separate fields.
+func isValue(t reflect.Type) bool {
+	if _, ok := typeHandler(t); ok {
+		return true
+	}
+	return reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+func getValues(c interface{}) reflect.Value {
+	// Make sure we give a sane error here when accidentally passing in a
+	// non-pointer, since the default is not all that helpful:
+	//     panic: reflect: call of reflect.Value.Elem on struct Value
+	defer func() {
+		err := recover()
+		if err != nil {
+			switch err.(type) {
+			case *reflect.ValueError:
+				panic(fmt.Errorf(
+					"unable to get values of the config struct (did you pass it as a pointer?): %v",
+					err))
+			default:
+				panic(err)
+			}
+		}
+	}()
+	return reflect.ValueOf(c).Elem()
+}
+
+func fmterr(file, line, key string, err error) error {
+	no, _ := strconv.Atoi(line)
+	if u, ok := err.(*UnknownOptionError); ok {
+		u.File, u.Line, u.Key = file, no, key
+		return u
+	}
+	return &ParseError{File: file, Line: no, Key: key, Err: err}
+}
+
+// ParseError is returned if a line can't be parsed, for example because the
+// value is invalid for the field's type or a Handler returned an error.
+type ParseError struct {
+	File string
+	Line int
+	Key  string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%v line %v: error parsing %s: %v", e.File, e.Line, e.Key, e.Err)
+}
+
+func (e *ParseError) Unwrap() error { return e.Err }
+
+// UnknownOptionError is returned if there is no field for a key.
+type UnknownOptionError struct {
+	File string
+	Line int
+	Key  string
+
+	// Field names that were tried, e.g. "Port" and "Ports". This is nil with
+	// Options.StrictTags, as only the struct tags are used.
+	TriedFields []string
+
+	reason string
+}
+
+func (e *UnknownOptionError) Error() string {
+	return fmt.Sprintf("%v line %v: error parsing %s: %s", e.File, e.Line, e.Key, e.reason)
+}
+
+func fieldNameFromKey(key string, values reflect.Value, strict bool) (string, error) {
+	fieldName := inflect.camelize(key)
+
+	if strict {
+		if !values.FieldByName(fieldName).CanAddr() {
+			return "", &UnknownOptionError{
+				TriedFields: []string{fieldName},
+				reason:      fmt.Sprintf("unknown option (field %s is missing)", fieldName),
+			}
+		}
+		return fieldName, nil
+	}
+
+	// This list is from golint
+	acr := []string{"Api", "Ascii", "Cpu", "Css", "Dns", "Eof", "Guid", "Html",
+		"Https", "Http", "Id", "Ip", "Json", "Lhs", "Qps", "Ram", "Rhs",
+		"Rpc", "Sla", "Smtp", "Sql", "Ssh", "Tcp", "Tls", "Ttl", "Udp",
+		"Ui", "Uid", "Uuid", "Uri", "Url", "Utf8", "Vm", "Xml", "Xsrf",
+		"Xss"}
+	for _, a := range acr {
+		fieldName = strings.Replace(fieldName, a, strings.ToUpper(a), -1)
+	}
+
+	field := values.FieldByName(fieldName)
+	if !field.CanAddr() {
+		// Check plural version too; we're not too fussy
+		fieldNamePlural := inflect.togglePlural(fieldName)
+		field = values.FieldByName(fieldNamePlural)
+		if !field.CanAddr() {
+			return "", &UnknownOptionError{
+				TriedFields: []string{fieldName, fieldNamePlural},
+				reason: fmt.Sprintf("unknown option (field %s or %s is missing)",
+					fieldName, fieldNamePlural),
+			}
+		}
+		fieldName = fieldNamePlural
+	}
+
+	return fieldName, nil
+}
+
+// resolveField finds the field for a key.
+//
+// A dotted key such as "server.tls.cert" sets fields in nested structs; the
+// name is inferred for every part, and pointers to structs are allocated if
+// they're nil. The returned fieldName is the full path, e.g. "Server.TLS.Cert".
+//
+// If a part of the key is a map then the rest of the key is used as the map
+// key; in this case the returned field is a new value which is added to the
+// map with commit, after it's set.
+//
+// Nil pointers are also only set with commit, so nothing is changed if the
+// field isn't allowed or can't be set.
+func resolveField(key string, values reflect.Value, opts Options) (
+	field reflect.Value, sf reflect.StructField, fieldName string, commit func(), err error,
+) {
+	parts := strings.Split(key, ".")
+	path := make([]string, 0, len(parts))
+	for i, p := range parts {
+		if i > 0 {
+			if values.Kind() == reflect.Ptr && values.Type().Elem().Kind() == reflect.Struct {
+				if values.IsNil() {
+					ptr, alloc := values, reflect.New(values.Type().Elem())
+					commit = chain(commit, func() { ptr.Set(alloc) })
+					values = alloc
+				}
+				values = values.Elem()
+			}
+			if values.Kind() == reflect.Map {
+				mapKey := strings.Join(parts[i:], ".")
+				var add func()
+				field, add, err = mapElem(values, mapKey)
+				if err != nil {
+					return field, sf, "", nil, err
+				}
+				return field, sf, strings.Join(append(path, mapKey), "."), chain(commit, add), nil
+			}
+			if values.Kind() != reflect.Struct {
+				return field, sf, "", nil, fmt.Errorf("%s is not a struct", strings.Join(path, "."))
+			}
+		}
+
+		name, ok := tagName(p, values.Type(), opts)
+		if !ok {
+			if opts.StrictTags {
+				return field, sf, "", nil, &UnknownOptionError{reason: fmt.Sprintf(
+					`unknown option (no field with the tag sconfig:"%s")`, p)}
+			}
+			name, err = fieldNameFromKey(p, values, opts.Strict)
+			if err != nil && opts.FoldKeys {
+				switch found := foldedFields(p, values.Type()); len(found) {
+				case 0:
+				case 1:
+					name, err = found[0], nil
+				default:
+					err = fmt.Errorf("ambiguous option (matches the fields %s)", strings.Join(found, ", "))
+				}
+			}
+			if err != nil {
+				return field, sf, "", nil, err
+			}
+		}
+
+		path = append(path, name)
+		sf, _ = values.Type().FieldByName(name)
+		values = values.FieldByName(name)
+	}
+	return values, sf, strings.Join(path, "."), commit, nil
+}
+
+// chain returns a function that runs a and then b; either can be nil.
+func chain(a, b func()) func() {
+	if a == nil {
+		return b
+	}
+	return func() {
+		a()
+		b()
+	}
+}
 
-func getValues(c interface{}) reflect.Value {
-	// Make sure we give a sane error here when accidentally passing in a
-	// non-pointer, since the default is not all that helpful:
-	//     panic: reflect: call of reflect.Value.Elem on struct Value
-	defer func() {
-		err := recover()
+// mapElem gets a new settable value for the map key, which is added to the
+// map with commit.
+//
+// The key is parsed with the type handler for the map's key type, or the
+// encoding.TextUnmarshaler interface.
+func mapElem(m reflect.Value, key string) (reflect.Value, func(), error) {
+	k, err := mapKey(m.Type().Key(), key)
+	if err != nil {
+		return reflect.Value{}, nil, err
+	}
+
+	elem := reflect.New(m.Type().Elem()).Elem()
+	if m.Len() > 0 {
+		if cur := m.MapIndex(k); cur.IsValid() {
+			elem.Set(cur)
+		}
+	}
+	return elem, func() {
+		if m.IsNil() {
+			m.Set(reflect.MakeMap(m.Type()))
+		}
+		m.SetMapIndex(k, elem)
+	}, nil
+}
+
+func mapKey(typ reflect.Type, key string) (reflect.Value, error) {
+	k := reflect.New(typ).Elem()
+	if typ.Kind() == reflect.String {
+		k.SetString(key)
+		return k, nil
+	}
+
+	if has, err := setFromTypeHandler(&k, Tag{}, []string{key}); has {
 		if err != nil {
-			switch err.(type) {
-			case *reflect.ValueError:
-				panic(fmt.Errorf(
-					"unable to get values of the config struct (did you pass it as a pointer?): %v",
-					err))
-			default:
-				panic(err)
-			}
+			return k, fmt.Errorf("invalid map key %q: %v", key, err)
 		}
-	}()
-	return reflect.ValueOf(c).Elem()
+		return k, nil
+	}
+	if m, ok := k.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := m.UnmarshalText([]byte(key)); err != nil {
+			return k, fmt.Errorf("invalid map key %q: %v", key, err)
+		}
+		return k, nil
+	}
+	return k, fmt.Errorf("don't know how to set map keys of the type %s", typ)
 }
 
-func fmterr(file, line, key string, err error) error {
-	return fmt.Errorf("%v line %v: error parsing %s: %v",
-		file, line, key, err)
+// foldedFields finds the fields for the key ignoring case, "-", and "_"; the
+// plural is also accepted, as with fieldNameFromKey().
+func foldedFields(key string, t reflect.Type) []string {
+	fold := func(s string) string {
+		return strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(s))
+	}
+
+	key = fold(key)
+	var found []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" { // Unexported
+			continue
+		}
+		if fold(f.Name) == key || fold(inflect.togglePlural(f.Name)) == key {
+			found = append(found, f.Name)
+		}
+	}
+	return found
 }
 
-func fieldNameFromKey(key string, values reflect.Value) (string, error) {
-	fieldName := inflect.camelize(key)
+// tagName finds the field with the key as the name in the struct tag.
+//
+// The names for a struct type are stored in opts.tagNames the first time it's
+// used, so every struct is only scanned once for every Parse() call.
+func tagName(key string, t reflect.Type, opts Options) (string, bool) {
+	names, ok := opts.tagNames[t]
+	if !ok {
+		names = make(map[string]string)
+		for i := 0; i < t.NumField(); i++ {
+			if n := parseTag(t.Field(i).Tag).Name; n != "" {
+				names[n] = t.Field(i).Name
+			}
+		}
+		if opts.tagNames != nil {
+			opts.tagNames[t] = names
+		}
+	}
+	name, ok := names[key]
+	return name, ok
+}
 
-	// This list is from golint
-	acr := []string{"Api", "Ascii", "Cpu", "Css", "Dns", "Eof", "Guid", "Html",
-		"Https", "Http", "Id", "Ip", "Json", "Lhs", "Qps", "Ram", "Rhs",
-		"Rpc", "Sla", "Smtp", "Sql", "Ssh", "Tcp", "Tls", "Ttl", "Udp",
-		"Ui", "Uid", "Uuid", "Uri", "Url", "Utf8", "Vm", "Xml", "Xsrf",
-		"Xss"}
-	for _, a := range acr {
-		fieldName = strings.Replace(fieldName, a, strings.ToUpper(a), -1)
+// allowField checks the field against Options.AllowFields and
+// Options.DenyFields. The field name is the full path (e.g. "TLS.Cert"), which
+// matches if it or any of its parents (e.g. "TLS") is in the list.
+func allowField(fieldName string, opts Options) error {
+	if opts.AllowFields != nil && !inPath(fieldName, opts.AllowFields) {
+		return fmt.Errorf("setting field %s is not allowed", fieldName)
+	}
+	if inPath(fieldName, opts.DenyFields) {
+		return fmt.Errorf("setting field %s is not allowed", fieldName)
 	}
+	return nil
+}
 
-	field := values.FieldByName(fieldName)
-	if !field.CanAddr() {
-		// Check plural version too; we're not too fussy
-		fieldNamePlural := inflect.togglePlural(fieldName)
-		field = values.FieldByName(fieldNamePlural)
-		if !field.CanAddr() {
-			return "", fmt.Errorf("unknown option (field %s or %s is missing)",
-				fieldName, fieldNamePlural)
+// inPath reports if the dotted path or any of its parents is in the list.
+func inPath(path string, list []string) bool {
+	for {
+		if inList(path, list) {
+			return true
 		}
-		fieldName = fieldNamePlural
+		i := strings.LastIndexByte(path, '.')
+		if i == -1 {
+			return false
+		}
+		path = path[:i]
 	}
+}
 
-	return fieldName, nil
+func recordRaw(fieldName string, line []string, opts Options) {
+	if opts.RawLines == nil {
+		return
+	}
+	no, _ := strconv.Atoi(line[0])
+	opts.RawLines[fieldName] = RawLine{Field: fieldName, Line: no, Text: line[2]}
 }
 
-func setFromHandler(fieldName string, values []string, handlers Handlers) (bool, error) {
-	if handlers == nil {
-		return false, nil
+// isClear reports if the slice field should be emptied because the first value
+// is the ClearKeyword.
+//
+// The \ in "\!clear" is removed by collapseWhitespace(), so check the raw
+// line to see if it was escaped.
+func isClear(field reflect.Value, fieldName string, v, line []string, opts Options) bool {
+	keyword := clearKeyword(opts)
+	if keyword == "" || len(v) < 2 || v[1] != keyword ||
+		!field.IsValid() || field.Kind() != reflect.Slice ||
+		opts.Handlers[fieldName] != nil || opts.HandlersCtx[fieldName] != nil {
+		return false
+	}
+	raw := strings.Fields(line[2])
+	return len(raw) > 1 && raw[1] == keyword
+}
+
+// lineFile gets the file the line is from, which is different from file for
+// lines in a sourced file.
+func lineFile(file string, line []string) string {
+	if len(line) > 3 {
+		return line[3]
+	}
+	return file
+}
+
+func recordSource(fieldName, file string, line []string, opts Options) {
+	if opts.Provenance == nil {
+		return
+	}
+	no, _ := strconv.Atoi(line[0])
+	kind := SourceFile
+	if opts.Handlers[fieldName] != nil || opts.HandlersCtx[fieldName] != nil {
+		kind = SourceHandler
+	}
+	opts.Provenance[fieldName] = Source{File: file, Line: no, Kind: kind}
+}
+
+// recordUnset adds all fields in the struct that aren't in opts.Provenance as
+// SourceUnset.
+func recordUnset(values reflect.Value, opts Options) {
+	fields := make(map[string]reflect.Value)
+	fieldsNested(fields, "", values)
+outer:
+	for name := range fields {
+		if _, ok := opts.Provenance[name]; ok {
+			continue
+		}
+		// Map keys such as "Headers.accept".
+		for p := range opts.Provenance {
+			if strings.HasPrefix(p, name+".") {
+				continue outer
+			}
+		}
+		opts.Provenance[name] = Source{Kind: SourceUnset}
+	}
+}
+
+func inList(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
 	}
+	return false
+}
 
-	handler, has := handlers[fieldName]
-	if !has {
+func setFromHandler(fieldName string, values []string, opts Options) (bool, error) {
+	var err error
+	if handler, has := opts.Handlers[fieldName]; has {
+		err = handler(values)
+	} else if handler, has := opts.HandlersCtx[fieldName]; has {
+		err = handler(opts.handlerCtx, values)
+	} else {
 		return false, nil
 	}
 
-	err := handler(values)
 	if err != nil {
 		return true, fmt.Errorf("%v (from handler)", err)
 	}
@@ -389,48 +2039,485 @@ func setFromHandler(fieldName string, values []string, handlers Handlers) (bool,
 	return true, nil
 }
 
-func setFromTypeHandler(field *reflect.Value, value []string) (bool, error) {
-	handler, has := typeHandlers[field.Type().String()]
-	if !has {
+// splitSep splits the values of slice fields on the separator from the "sep"
+// tag option or Options.SliceSep.
+func splitSep(field reflect.Value, tag Tag, values []string, opts Options) []string {
+	sep := opts.SliceSep
+	if tag.Has("sep") {
+		sep = tag.Get("sep")
+	}
+	if sep == "" || field.Kind() != reflect.Slice {
+		return values
+	}
+
+	var r []string
+	for _, v := range strings.Split(strings.Join(values, " "), sep) {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// setter is implemented by types with a Set() method, such as flag.Value.
+type setter interface {
+	Set(string) error
+}
+
+// setFromSetter sets the field with the Set() method; all values are joined
+// with a space. For slices Set() is called on a new element for every value.
+func setFromSetter(field reflect.Value, values []string) (bool, error) {
+	if field.Kind() == reflect.Slice {
+		if !isSetter(field.Type().Elem()) {
+			return false, nil
+		}
+		a := field
+		for _, v := range values {
+			elem := reflect.New(field.Type().Elem()).Elem()
+			if err := callSet(elem, v); err != nil {
+				return true, err
+			}
+			a = reflect.Append(a, elem)
+		}
+		field.Set(a)
+		return true, nil
+	}
+
+	if !isSetter(field.Type()) {
+		return false, nil
+	}
+	return true, callSet(field, strings.Join(values, " "))
+}
+
+// setStructSlice appends an element to a slice of structs (or pointers to
+// structs), where every value sets the next exported field of the struct.
+func setStructSlice(field reflect.Value, values []string) (bool, error) {
+	if field.Kind() != reflect.Slice {
+		return false, nil
+	}
+	typ := field.Type().Elem()
+	isPtr := typ.Kind() == reflect.Ptr
+	if isPtr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct {
+		return false, nil
+	}
+
+	var fields []reflect.StructField
+	for i := 0; i < typ.NumField(); i++ {
+		if typ.Field(i).PkgPath == "" { // Exported
+			fields = append(fields, typ.Field(i))
+		}
+	}
+	if len(values) != len(fields) {
+		names := make([]string, len(fields))
+		for i := range fields {
+			names[i] = fields[i].Name
+		}
+		return true, fmt.Errorf("must have %d values for the fields %s (has: %d)",
+			len(fields), strings.Join(names, ", "), len(values))
+	}
+
+	elem := reflect.New(typ)
+	for i, f := range fields {
+		err := setField(elem.Elem().FieldByIndex(f.Index), f.Name, parseTag(f.Tag), values[i:i+1], Options{})
+		if err != nil {
+			return true, fmt.Errorf("%s: %v", f.Name, err)
+		}
+	}
+	if !isPtr {
+		elem = elem.Elem()
+	}
+	field.Set(reflect.Append(field, elem))
+	return true, nil
+}
+
+// setFromJSON sets the field with the json.Unmarshaler interface; the values
+// are joined with a space and used as the JSON text.
+func setFromJSON(field reflect.Value, values []string) (bool, error) {
+	if field.Kind() != reflect.Ptr && field.CanAddr() {
+		field = field.Addr()
+	}
+	if _, ok := field.Interface().(json.Unmarshaler); !ok {
 		return false, nil
 	}
+	if field.IsNil() {
+		field.Set(reflect.New(field.Type().Elem()))
+	}
+	return true, field.Interface().(json.Unmarshaler).UnmarshalJSON([]byte(strings.Join(values, " ")))
+}
+
+func isSetter(t reflect.Type) bool {
+	s := reflect.TypeOf((*setter)(nil)).Elem()
+	return t.Implements(s) || reflect.PtrTo(t).Implements(s)
+}
+
+// callSet calls Set() on v, allocating it first if it's a nil pointer.
+func callSet(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		v.Set(reflect.New(v.Type().Elem()))
+	}
+	if m, ok := v.Interface().(setter); ok {
+		return m.Set(s)
+	}
+	return v.Addr().Interface().(setter).Set(s)
+}
+
+// intern the strings in a string field, or a slice of strings starting at
+// index start.
+func intern(field reflect.Value, start int, interned map[string]string) {
+	get := func(v reflect.Value) {
+		s := v.String()
+		if i, ok := interned[s]; ok {
+			v.SetString(i)
+		} else {
+			interned[s] = s
+		}
+	}
+
+	switch {
+	case field.Kind() == reflect.String:
+		get(field)
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		for i := start; i < field.Len(); i++ {
+			get(field.Index(i))
+		}
+	}
+}
+
+// increment an integer field by one.
+func increment(field reflect.Value) error {
+	switch {
+	case field.Kind() >= reflect.Int && field.Kind() <= reflect.Int64:
+		field.SetInt(field.Int() + 1)
+	case field.Kind() >= reflect.Uint && field.Kind() <= reflect.Uint64:
+		field.SetUint(field.Uint() + 1)
+	default:
+		return fmt.Errorf("the count option can't be used on fields of the type %s", field.Type())
+	}
+	return nil
+}
+
+// runValidators runs all but the last type handler for the field's type.
+func runValidators(field reflect.Value, values []string) error {
+	if !field.IsValid() {
+		return nil
+	}
+	handler, _ := typeHandler(field.Type())
+	for i := 0; i < len(handler)-1; i++ {
+		if _, err := handler[i](values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetValue sets v from the values with the registered type handler for v's
+// type, as if it were a struct field. It returns false if there is no type
+// handler for the type.
+//
+// The value must be settable (see reflect.Value.CanSet()), such as a field of
+// a struct obtained through a pointer or reflect.New(typ).Elem(); it's an
+// error otherwise. Like struct fields, values are appended to slices.
+//
+// This is useful for frameworks which already have a reflect.Value and want
+// to use sconfig's type handlers.
+func SetValue(v reflect.Value, values []string) (bool, error) {
+	if !v.CanSet() {
+		return false, fmt.Errorf("sconfig.SetValue: value of type %s is not settable", v.Type())
+	}
+	return setFromTypeHandler(&v, Tag{}, values)
+}
 
+func setFromTypeHandler(field *reflect.Value, tag Tag, value []string) (bool, error) {
 	var (
 		v   interface{}
 		err error
 	)
-	for _, h := range handler {
-		v, err = h(value)
+	if th, has := tagHandler(field.Type()); has {
+		v, err = th(tag, value)
 		if err != nil {
 			return true, err
 		}
+	} else {
+		handler, has := typeHandler(field.Type())
+		if !has {
+			switch {
+			case field.Kind() == reflect.Ptr:
+				return setPointer(field, tag, value)
+			case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Slice:
+				return setRow(field, value)
+			case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Ptr:
+				return setPointerSlice(field, tag, value)
+			}
+			return false, nil
+		}
+		for _, h := range handler {
+			v, err = h(value)
+			if err != nil {
+				return true, err
+			}
+		}
 	}
 
-	val := reflect.ValueOf(v)
-	if field.Kind() == reflect.Slice {
-		val = reflect.AppendSlice(*field, val)
+	return true, setTypeHandlerValue(field, tag, v)
+}
+
+// typeHandler gets the type handlers for the type, using the qualified name
+// (e.g. "*text/template.Template") if it's registered, or the short name
+// (e.g. "*template.Template") if it's not.
+func typeHandler(typ reflect.Type) ([]TypeHandler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	if h, ok := typeHandlers[qualifiedName(typ)]; ok {
+		return h, ok
+	}
+	h, ok := typeHandlers[typ.String()]
+	return h, ok
+}
+
+// tagHandler is like typeHandler(), for tag handlers.
+func tagHandler(typ reflect.Type) (TagHandler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	if h, ok := tagHandlers[qualifiedName(typ)]; ok {
+		return h, ok
+	}
+	h, ok := tagHandlers[typ.String()]
+	return h, ok
+}
+
+// qualifiedName is like reflect.Type.String(), but with the full package path
+// instead of just the package name: "*text/template.Template" instead of
+// "*template.Template".
+func qualifiedName(typ reflect.Type) string {
+	if typ.Name() == "" {
+		switch typ.Kind() {
+		case reflect.Ptr:
+			return "*" + qualifiedName(typ.Elem())
+		case reflect.Slice:
+			return "[]" + qualifiedName(typ.Elem())
+		}
+	}
+	if typ.PkgPath() == "" {
+		return typ.String()
+	}
+	return typ.PkgPath() + "." + typ.Name()
+}
+
+// setTypeHandlerValue sets the value returned from a type handler on the field;
+// slices are appended to.
+func setTypeHandlerValue(field *reflect.Value, tag Tag, v interface{}) error {
+	val := convert(reflect.ValueOf(v), field.Type())
+	if !val.IsValid() || !val.Type().AssignableTo(field.Type()) {
+		return fmt.Errorf("handler returned %T for a field of the type %s", v, field.Type())
+	}
+	switch field.Kind() {
+	case reflect.Slice:
+		var err error
+		val, err = sliceOptions(reflect.AppendSlice(*field, val), tag)
+		if err != nil {
+			return err
+		}
+	case reflect.Map:
+		if !field.IsNil() {
+			mergeMap(*field, val)
+			return nil
+		}
+	}
+	field.Set(val)
+	return nil
+}
+
+// mergeMap adds all keys from src to dst; slice values are appended to.
+func mergeMap(dst, src reflect.Value) {
+	iter := src.MapRange()
+	for iter.Next() {
+		v := iter.Value()
+		if cur := dst.MapIndex(iter.Key()); cur.IsValid() && v.Kind() == reflect.Slice {
+			v = reflect.AppendSlice(cur, v)
+		}
+		dst.SetMapIndex(iter.Key(), v)
+	}
+}
+
+// setRow appends the values as a new row to a slice of slices (e.g.
+// [][]string), using the type handler for the inner slice.
+func setRow(field *reflect.Value, value []string) (bool, error) {
+	row := reflect.New(field.Type().Elem()).Elem()
+	has, err := setFromTypeHandler(&row, Tag{}, value)
+	if !has || err != nil {
+		return has, err
+	}
+	field.Set(reflect.Append(*field, row))
+	return true, nil
+}
+
+// setPointer sets a pointer field (e.g. *int64) with the type handler for the
+// type it points to. A nil pointer is allocated, and an existing value is
+// re-used.
+func setPointer(field *reflect.Value, tag Tag, value []string) (bool, error) {
+	if !field.IsNil() {
+		elem := field.Elem()
+		return setFromTypeHandler(&elem, tag, value)
+	}
+
+	p := reflect.New(field.Type().Elem())
+	elem := p.Elem()
+	has, err := setFromTypeHandler(&elem, tag, value)
+	if !has || err != nil {
+		return has, err
+	}
+	field.Set(p)
+	return true, nil
+}
+
+// setPointerSlice sets a slice of pointers (e.g. []*int64) with the type handler
+// for the slice of the type it points to.
+func setPointerSlice(field *reflect.Value, tag Tag, value []string) (bool, error) {
+	elems := reflect.New(reflect.SliceOf(field.Type().Elem().Elem())).Elem()
+	has, err := setFromTypeHandler(&elems, Tag{}, value)
+	if !has || err != nil {
+		return has, err
+	}
+
+	val := *field
+	for i := 0; i < elems.Len(); i++ {
+		val = reflect.Append(val, elems.Index(i).Addr())
+	}
+	val, err = sliceOptions(val, tag)
+	if err != nil {
+		return true, err
+	}
+	field.Set(val)
+	return true, nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setTextSlice sets a slice of a type that implements encoding.TextUnmarshaler,
+// such as []*T or []T. Every value is unmarshaled in to a new element, and
+// nothing is set if there's an error for any of them.
+func setTextSlice(field reflect.Value, tag Tag, values []string) (bool, error) {
+	if field.Kind() != reflect.Slice {
+		return false, nil
+	}
+	typ := field.Type().Elem()
+	isPtr := typ.Kind() == reflect.Ptr
+	if isPtr {
+		typ = typ.Elem()
+	}
+	if !reflect.PtrTo(typ).Implements(textUnmarshalerType) {
+		return false, nil
+	}
+
+	val := field
+	for _, v := range values {
+		e := reflect.New(typ)
+		if err := e.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(v)); err != nil {
+			return true, err
+		}
+		if !isPtr {
+			e = e.Elem()
+		}
+		val = reflect.Append(val, e)
+	}
+	val, err := sliceOptions(val, tag)
+	if err != nil {
+		return true, err
 	}
 	field.Set(val)
 	return true, nil
 }
 
+// convert val to typ if the handler returned a different type with the same
+// underlying kind, such as an uint64 for a "type Caps uint64" field. Any of the
+// integer kinds can be converted to each other.
+func convert(val reflect.Value, typ reflect.Type) reflect.Value {
+	if !val.IsValid() || val.Type() == typ || !val.Type().ConvertibleTo(typ) {
+		return val
+	}
+	if val.Kind() == typ.Kind() || (isInt(val.Kind()) && isInt(typ.Kind())) {
+		return val.Convert(typ)
+	}
+	return val
+}
+
+func isInt(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
 // FindConfig tries to find a configuration file at the usual locations.
 //
 // The following paths are checked (in this order):
 //
-//   $XDG_CONFIG/<file>
+//   $XDG_CONFIG_HOME/<file>
 //   $HOME/.<file>
+//   $XDG_CONFIG_DIRS/<file>   (every directory in the list)
 //   /etc/<file>
 //   /usr/local/etc/<file>
 //   /usr/pkg/etc/<file>
 //   ./<file>
 //
-// The default for $XDG_CONFIG is $HOME/.config if it's not set.
+// The default for $XDG_CONFIG_HOME is $HOME/.config if it's not set, and the
+// default for $XDG_CONFIG_DIRS is /etc/xdg.
+//
+// $XDG_CONFIG is used if it's set and $XDG_CONFIG_HOME isn't; this is
+// deprecated and will be removed in the next release.
 func FindConfig(file string) string {
+	for _, l := range configLocations(file) {
+		if _, err := os.Stat(l); err == nil {
+			return l
+		}
+	}
+
+	return ""
+}
+
+// FindConfigAll is like FindConfig(), but returns all the files that exist, in
+// the reverse order of FindConfig(). This is useful for layered configuration
+// files, where every file is parsed in turn so that later files override
+// earlier ones:
+//
+//     err := sconfig.SetDefaults(&c)
+//     ...
+//     for _, f := range sconfig.FindConfigAll("myapp/config") {
+//         err := sconfig.ParseWith(&c, f, sconfig.Options{NoDefaults: true})
+//         ...
+//     }
+//
+// The defaults are set once before parsing, as Parse() would set the
+// default for a field set to the zero value in an earlier file.
+//
+// The file that FindConfig() would return is last. It returns an empty slice
+// if none of the files exist.
+func FindConfigAll(file string) []string {
+	locations := configLocations(file)
+	found := []string{}
+	for i := len(locations) - 1; i >= 0; i-- {
+		if _, err := os.Stat(locations[i]); err == nil {
+			found = append(found, locations[i])
+		}
+	}
+	return found
+}
+
+// configLocations gets all locations for FindConfig(), in order.
+func configLocations(file string) []string {
 	file = strings.TrimLeft(file, "/")
 
 	locations := []string{}
-	xdg := os.Getenv("XDG_CONFIG")
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		xdg = os.Getenv("XDG_CONFIG") // Deprecated.
+	}
 	if xdg != "" {
 		locations = append(locations, filepath.Join(xdg, file))
 	}
@@ -442,18 +2529,20 @@ func FindConfig(file string) string {
 		locations = append(locations, home+"/."+file)
 	}
 
-	locations = append(locations, []string{
+	dirs := os.Getenv("XDG_CONFIG_DIRS")
+	if dirs == "" {
+		dirs = "/etc/xdg"
+	}
+	for _, d := range strings.Split(dirs, ":") {
+		if d != "" {
+			locations = append(locations, filepath.Join(d, file))
+		}
+	}
+
+	return append(locations, []string{
 		"/etc/" + file,
 		"/usr/local/etc/" + file,
 		"/usr/pkg/etc/" + file,
 		"./" + file,
 	}...)
-
-	for _, l := range locations {
-		if _, err := os.Stat(l); err == nil {
-			return l
-		}
-	}
-
-	return ""
 }
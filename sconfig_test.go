@@ -1,13 +1,18 @@
 package sconfig
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -44,7 +49,7 @@ func rmAll(t *testing.T, path string) {
 
 func TestRegisterType(t *testing.T) {
 	defer func() {
-		typeHandlers["int64"] = []TypeHandler{ValidateSingleValue(), handleInt64}
+		typeHandlers["int64"] = []TypeHandler{ValidateSingleValue(), HandleInt64}
 		delete(typeHandlers, "int")
 	}()
 
@@ -80,6 +85,210 @@ func TestRegisterType(t *testing.T) {
 	}
 }
 
+func TestRegisterTypeValidators(t *testing.T) {
+	defer delete(typeHandlers, "int")
+
+	var ran []string
+	validator := func(name string, fail bool) TypeHandler {
+		return func(v []string) (interface{}, error) {
+			ran = append(ran, name)
+			if fail {
+				return nil, fmt.Errorf("%s failed", name)
+			}
+			return "ignored", nil
+		}
+	}
+	handler := func(v []string) (interface{}, error) {
+		ran = append(ran, "handler")
+		return int(len(v)), nil
+	}
+
+	f := testfile("value a b c")
+	defer rm(t, f)
+
+	tests := []struct {
+		fun     []TypeHandler
+		want    []string
+		wantErr string
+	}{
+		{[]TypeHandler{handler}, []string{"handler"}, ""},
+		{[]TypeHandler{validator("a", false), validator("b", false), handler}, []string{"a", "b", "handler"}, ""},
+		{[]TypeHandler{validator("a", false), validator("b", true), handler}, []string{"a", "b"}, "b failed"},
+		{[]TypeHandler{validator("a", true), validator("b", false), handler}, []string{"a"}, "a failed"},
+	}
+
+	for _, tc := range tests {
+		t.Run("", func(t *testing.T) {
+			ran = nil
+			RegisterType("int", tc.fun...)
+
+			var c struct{ Value int }
+			err := Parse(&c, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(ran, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, ran)
+			}
+			if tc.wantErr == "" && c.Value != 3 {
+				t.Errorf("wrong value: %d", c.Value)
+			}
+		})
+	}
+
+	t.Run("no handler", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("didn't panic")
+			}
+		}()
+		RegisterType("int")
+	})
+}
+
+func TestHandleRune(t *testing.T) {
+	defer func() {
+		delete(typeHandlers, "int32")
+		delete(typeHandlers, "uint8")
+	}()
+	RegisterType("int32", ValidateSingleValue(), HandleRune)
+	RegisterType("uint8", ValidateSingleValue(), HandleByte)
+
+	tests := []struct {
+		in      string
+		want    rune
+		wantErr string
+	}{
+		{"sep ;", ';', ""},
+		{"sep 59", ';', ""},
+		{"sep \\#", '#', ""},
+		{"sep ; ;", 0, "must have exactly one value"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in + "\nbyte " + tc.in[4:])
+			defer rm(t, f)
+
+			var out struct {
+				Sep  rune
+				Byte byte
+			}
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if out.Sep != tc.want || out.Byte != byte(tc.want) {
+				t.Errorf("wrong: %q %q", out.Sep, out.Byte)
+			}
+		})
+	}
+}
+
+type testCaps uint64
+
+func TestRegisterFlags(t *testing.T) {
+	defer func() {
+		delete(typeHandlers, "sconfig.testCaps")
+		delete(typeHandlers, "int")
+	}()
+
+	flags := map[string]uint64{"read": 1 << 0, "write": 1 << 1, "exec": 1 << 2}
+	RegisterFlags("sconfig.testCaps", flags)
+	RegisterFlags("int", flags)
+
+	t.Run("set", func(t *testing.T) {
+		f := testfile("caps read exec\nperm write write")
+		defer rm(t, f)
+
+		c := &struct {
+			Caps testCaps
+			Perm int
+		}{}
+		err := Parse(c, f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Caps != 5 {
+			t.Errorf("Caps wrong: %d", c.Caps)
+		}
+		if c.Perm != 2 {
+			t.Errorf("Perm wrong: %d", c.Perm)
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		f := testfile("caps read delete")
+		defer rm(t, f)
+
+		c := &struct{ Caps testCaps }{}
+		err := Parse(c, f, nil)
+		want := `line 1: error parsing caps: unknown flag "delete"`
+		if err == nil || !strings.HasSuffix(err.Error(), want) {
+			t.Errorf("\nwant: %v\nout:  %v", want, err)
+		}
+	})
+}
+
+type testMode int
+
+const (
+	modeFast testMode = iota + 1
+	modeSafe
+	modeExperimental
+)
+
+func TestRegisterEnum(t *testing.T) {
+	defer func() {
+		delete(typeHandlers, "sconfig.testMode")
+		delete(typeHandlers, "[]sconfig.testMode")
+		delete(marshalers, "sconfig.testMode")
+	}()
+
+	RegisterEnum("sconfig.testMode", map[string]interface{}{
+		"fast":         modeFast,
+		"safe":         modeSafe,
+		"experimental": modeExperimental,
+	})
+
+	type config struct {
+		Mode     testMode
+		Features []testMode
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"mode safe", config{Mode: modeSafe}, ""},
+		{"features fast,safe,experimental", config{Features: []testMode{modeFast, modeSafe, modeExperimental}}, ""},
+		{"features fast safe", config{Features: []testMode{modeFast, modeSafe}}, ""},
+		{"features fast, safe\nfeatures experimental", config{Features: []testMode{modeFast, modeSafe, modeExperimental}}, ""},
+
+		{"mode slow", config{}, `unknown value "slow" (valid: experimental, fast, safe)`},
+		{"mode fast safe", config{}, `must have exactly one value`},
+		{"features fast,slow,safe", config{}, `unknown value "slow" at position 2 (valid: experimental, fast, safe)`},
+		{"features fast safe slow", config{}, `unknown value "slow" at position 3`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
 func TestReadFileError(t *testing.T) {
 	// File doesn't exist
 	out, err := readFile("/nonexistent-file")
@@ -135,8 +344,8 @@ uni-code    　 white    　 space   　
 pre_serve \ spaces \ \ like \		\	so
 
 back s\\la\sh
-
-
+key a\ \ b
+key a\ b
 source %v
 
 `, source)
@@ -150,6 +359,8 @@ source %v
 		{"15", "uni-code white space"},
 		{"16", "pre_serve  spaces   like 		so"},
 		{"18", `back s\lash`},
+		{"19", "key a  b"},
+		{"20", "key a b"},
 		{"1", "sourced file"},
 	}
 
@@ -209,6 +420,103 @@ func TestFindConfig(t *testing.T) {
 	//t.Fail()
 }
 
+func TestFindConfigAll(t *testing.T) {
+	if f := FindConfigAll("sure_this_wont_exist/anywhere"); f == nil || len(f) != 0 {
+		t.Errorf("not an empty slice: %#v", f)
+	}
+
+	dir, err := ioutil.TempDir(os.TempDir(), "sconfig_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rmAll(t, dir)
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	xdg := filepath.Join(dir, "xdg")
+	if err := os.Mkdir(xdg, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	defer os.Setenv("XDG_CONFIG_DIRS", os.Getenv("XDG_CONFIG_DIRS"))
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("XDG_CONFIG_HOME", xdg)
+	os.Setenv("XDG_CONFIG_DIRS", filepath.Join(dir, "dirs"))
+	os.Setenv("HOME", dir)
+	if err := os.Mkdir("dirs", 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"config", "xdg/config", "dirs/config"} {
+		if err := ioutil.WriteFile(f, []byte("str "+f), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	find := FindConfigAll("config")
+	want := []string{"./config", filepath.Join(dir, "dirs/config"), filepath.Join(xdg, "config")}
+	if !reflect.DeepEqual(find, want) {
+		t.Fatalf("\nwant: %#v\nout:  %#v\n", want, find)
+	}
+	if FindConfig("config") != find[len(find)-1] {
+		t.Errorf("FindConfig() not last: %q", FindConfig("config"))
+	}
+
+	var c testPrimitives
+	for _, f := range find {
+		if err := Parse(&c, f, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.Str != "xdg/config" {
+		t.Errorf("wrong: %q", c.Str)
+	}
+}
+
+func TestConfigLocations(t *testing.T) {
+	for _, k := range []string{"HOME", "XDG_CONFIG", "XDG_CONFIG_HOME", "XDG_CONFIG_DIRS"} {
+		defer os.Setenv(k, os.Getenv(k))
+	}
+	sys := []string{"/etc/f", "/usr/local/etc/f", "/usr/pkg/etc/f", "./f"}
+
+	tests := []struct {
+		env  map[string]string
+		want []string
+	}{
+		{map[string]string{},
+			[]string{"/etc/xdg/f"}},
+		{map[string]string{"HOME": "/h"},
+			[]string{"/h/.config/f", "/h/.f", "/etc/xdg/f"}},
+		{map[string]string{"HOME": "/h", "XDG_CONFIG_HOME": "/x"},
+			[]string{"/x/f", "/h/.f", "/etc/xdg/f"}},
+		{map[string]string{"HOME": "/h", "XDG_CONFIG": "/old"},
+			[]string{"/old/f", "/h/.f", "/etc/xdg/f"}},
+		{map[string]string{"XDG_CONFIG_HOME": "/x", "XDG_CONFIG": "/old"},
+			[]string{"/x/f", "/etc/xdg/f"}},
+		{map[string]string{"XDG_CONFIG_DIRS": "/a::/b/"},
+			[]string{"/a/f", "/b/f"}},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			for _, k := range []string{"HOME", "XDG_CONFIG", "XDG_CONFIG_HOME", "XDG_CONFIG_DIRS"} {
+				os.Setenv(k, tc.env[k])
+			}
+			out := configLocations("f")
+			want := append(tc.want, sys...)
+			if !reflect.DeepEqual(out, want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+			}
+		})
+	}
+}
+
 type testPrimitives struct {
 	Str     string
 	Int64   int64
@@ -259,6 +567,38 @@ func TestParseError(t *testing.T) {
 
 }
 
+func TestErrorTypes(t *testing.T) {
+	f := testfile("port 1\nnot okay\nport x")
+	defer rm(t, f)
+
+	var c struct{ Port int64 }
+	err := ParseAll(&c, f, nil)
+	errs := err.(*MultiError).Errors()
+	if len(errs) != 2 {
+		t.Fatalf("wrong number of errors: %v", err)
+	}
+
+	var unknown *UnknownOptionError
+	if !errors.As(errs[0], &unknown) {
+		t.Fatalf("not an UnknownOptionError: %#v", errs[0])
+	}
+	if unknown.File != f || unknown.Line != 2 || unknown.Key != "not" ||
+		!reflect.DeepEqual(unknown.TriedFields, []string{"Not", "Nots"}) {
+		t.Errorf("wrong: %#v", unknown)
+	}
+
+	var perr *ParseError
+	if !errors.As(errs[1], &perr) {
+		t.Fatalf("not a ParseError: %#v", errs[1])
+	}
+	if perr.File != f || perr.Line != 3 || perr.Key != "port" {
+		t.Errorf("wrong: %#v", perr)
+	}
+	if !errors.Is(perr, strconv.ErrSyntax) {
+		t.Errorf("wrong Err: %#v", perr.Err)
+	}
+}
+
 // Make sure we give a sane error
 func TestGetValues(t *testing.T) {
 	out := struct {
@@ -319,7 +659,7 @@ func TestInvalidPrimitives(t *testing.T) {
 		"\n\nInt64 false":            `line 3: error parsing Int64: strconv.ParseInt: parsing "false": invalid syntax`,
 		"Bool what?":                 `line 1: error parsing Bool: unable to parse "what?" as a boolean`,
 		"woot field":                 `line 1: error parsing woot: unknown option (field Woot or Woots is missing)`,
-		"\n\n\n\ntime-type 2016\n\n": `line 5: error parsing time-type: don't know how to set fields of the type time.Time`,
+		"\n\n\n\ntime-type 2016\n\n": `line 5: error parsing time-type: parsing time "2016" as "2006-01-02T15:04:05Z07:00": cannot parse "" as "-"`,
 
 		"float32 42,42": `invalid syntax`,
 		"float64 42,42": `invalid syntax`,
@@ -327,8 +667,13 @@ func TestInvalidPrimitives(t *testing.T) {
 		"int64 nope":  `invalid syntax`,
 		"uint64 nope": `invalid syntax`,
 
-		`int64 1 2`: `line 1: error parsing int64: must have exactly one value`,
-		`uint64`:    `line 1: error parsing uint64: must have exactly one value`,
+		`int64 1 2`:   `line 1: error parsing int64: must have exactly one value`,
+		`uint64`:      `line 1: error parsing uint64: must have exactly one value`,
+		`u-int64 1 2`: `line 1: error parsing u-int64: must have exactly one value`,
+		`float32 1 2`: `line 1: error parsing float32: must have exactly one value`,
+		`float64`:     `line 1: error parsing float64: must have exactly one value`,
+		`bool yes no`: `line 1: error parsing bool: must have at most one value`,
+		`bool2 1 0`:   `line 1: error parsing bool2: must have at most one value`,
 	}
 
 	for test, expected := range tests {
@@ -406,6 +751,101 @@ func TestParseHandlers(t *testing.T) {
 	}
 }
 
+func TestChainHandlers(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr string
+	}{
+		{"str hello", []string{"validate hello", "set hello"}, ""},
+		{"str", []string{"validate "}, "error parsing str: need a value (from handler)"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var (
+				out    testPrimitives
+				called []string
+			)
+			err := Parse(&out, f, Handlers{"Str": ChainHandlers(
+				func(v []string) error {
+					called = append(called, "validate "+strings.Join(v, " "))
+					if len(v) == 0 {
+						return errors.New("need a value")
+					}
+					return nil
+				},
+				func(v []string) error {
+					called = append(called, "set "+v[0])
+					out.Str = strings.ToUpper(v[0])
+					return nil
+				},
+			)})
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(called, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, called)
+			}
+			if tc.wantErr == "" && out.Str != "HELLO" {
+				t.Errorf("wrong value: %q", out.Str)
+			}
+		})
+	}
+}
+
+func TestValidateHandlers(t *testing.T) {
+	tests := []struct {
+		in       string
+		validate bool
+		want     string
+		wantErr  string
+	}{
+		{"int64 0x10", false, "0x10", ""},
+		{"int64 0x10", true, "0x10", ""},
+		{"int64 1 2", false, "1", ""},
+		{"int64 1 2", true, "", "error parsing int64: must have exactly one value"},
+		{"int64", true, "", "error parsing int64: must have exactly one value"},
+		{"str-slice one two", true, "one", ""},
+		{"str-slice", true, "", "error parsing str-slice: must have more than 1 values"},
+
+		// No validators for string.
+		{"str one two", true, "one", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s %t", tc.in, tc.validate), func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out struct {
+				Str      string
+				StrSlice []string
+				Int64    int64
+			}
+			var got string
+			set := func(line []string) error {
+				got = line[0]
+				return nil
+			}
+
+			err := ParseWith(&out, f, Options{
+				ValidateHandlers: tc.validate,
+				Handlers:         Handlers{"Str": set, "StrSlice": set, "Int64": set},
+			})
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("\nwant: %q\nout:  %q\n", tc.want, got)
+			}
+		})
+	}
+}
+
 type testArray struct {
 	Str      []string
 	Int64    []int64
@@ -448,12 +888,113 @@ float64 3.14159 1.2
 	}
 }
 
+func TestClearKeyword(t *testing.T) {
+	inc := testfile("str a b\nint64 1 2")
+	defer rm(t, inc)
+
+	tests := []struct {
+		in      string
+		keyword string
+		want    testArray
+		wantErr string
+	}{
+		{"str !clear", "!clear", testArray{Str: []string{}, Int64: []int64{1, 2}}, ""},
+		{"str !clear c d", "!clear", testArray{Str: []string{"c", "d"}, Int64: []int64{1, 2}}, ""},
+		{"str !clear\nstr c", "!clear", testArray{Str: []string{"c"}, Int64: []int64{1, 2}}, ""},
+		{"str c\nstr !clear d\nint64 !clear", "!clear", testArray{Str: []string{"d"}, Int64: []int64{}}, ""},
+		{"str\n  !clear c", "!clear", testArray{Str: []string{"c"}, Int64: []int64{1, 2}}, ""},
+		{"str c !clear", "!clear", testArray{Str: []string{"a", "b", "c", "!clear"}, Int64: []int64{1, 2}}, ""},
+		{"str \\!clear c", "!clear", testArray{Str: []string{"a", "b", "!clear", "c"}, Int64: []int64{1, 2}}, ""},
+		{"str !clear", "", testArray{Str: []string{"a", "b", "!clear"}, Int64: []int64{1, 2}}, ""},
+		{"str !reset c", "!reset", testArray{Str: []string{"c"}, Int64: []int64{1, 2}}, ""},
+		{"int64 !clear x", "!clear", testArray{Str: []string{"a", "b"}, Int64: []int64{1, 2}}, `parsing "x": invalid syntax`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			defer func(k string) { ClearKeyword = k }(ClearKeyword)
+			ClearKeyword = tc.keyword
+
+			f := testfile("source " + inc + "\n" + tc.in)
+			defer rm(t, f)
+
+			var out testArray
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		f := testfile("int64 1 2\nint64 !clear x\nstr !clear a")
+		defer rm(t, f)
+
+		var out testArray
+		err := ParseAll(&out, f, nil)
+		if !errorContains(err, `line 2: error parsing int64: strconv.ParseInt: parsing "x"`) {
+			t.Fatalf("wrong error: %v", err)
+		}
+		want := testArray{Int64: []int64{1, 2}, Str: []string{"a"}}
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		for _, tc := range []struct {
+			keyword string
+			in      string
+			want    []string
+		}{
+			{"", "str !clear c", []string{"c"}},
+			{"!reset", "str !reset c\nstr !clear", []string{"c", "!clear"}},
+			{"-", "str !clear c", []string{"a", "b", "!clear", "c"}},
+		} {
+			f := testfile("source " + inc + "\n" + tc.in)
+			defer rm(t, f)
+
+			var out testArray
+			err := ParseWith(&out, f, Options{ClearKeyword: tc.keyword})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(out.Str, tc.want) {
+				t.Errorf("%q\nwant: %#v\nout:  %#v\n", tc.keyword, tc.want, out.Str)
+			}
+			if ClearKeyword != "!clear" {
+				t.Errorf("ClearKeyword changed to %q", ClearKeyword)
+			}
+		}
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		f := testfile("str !clear a")
+		defer rm(t, f)
+
+		var (
+			out testArray
+			got []string
+		)
+		err := Parse(&out, f, Handlers{"Str": func(v []string) error { got = v; return nil }})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"!clear", "a"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("\nwant: %#v\nout:  %#v\n", want, got)
+		}
+	})
+}
+
 func TestInvalidArray(t *testing.T) {
 	tests := map[string]string{
 		"\n\nInt64 false":            `line 3: error parsing Int64: strconv.ParseInt: parsing "false": invalid syntax`,
 		"Bool what?":                 `line 1: error parsing Bool: unable to parse "what?" as a boolean`,
 		"woot field":                 `line 1: error parsing woot: unknown option (field Woot or Woots is missing)`,
-		"\n\n\n\ntime-type 2016\n\n": `line 5: error parsing time-type: don't know how to set fields of the type []time.Time`,
+		"\n\n\n\ntime-type 2016\n\n": `line 5: error parsing time-type: parsing time "2016" as "2006-01-02T15:04:05Z07:00": cannot parse "" as "-"`,
 
 		"float32 42,42": `invalid syntax`,
 		"float64 42,42": `invalid syntax`,
@@ -496,6 +1037,71 @@ func TestInflect(t *testing.T) {
 	}
 }
 
+// Repeated singular keys should append to the plural slice field, rather than
+// overwriting it.
+func TestInflectAppend(t *testing.T) {
+	c := &struct {
+		Hosts []string
+		Ports []int64
+	}{}
+
+	f := testfile("host a.com\nport 80\nhost b.com\nhosts c.com d.com\nport 443\nhost e.com")
+	defer rm(t, f)
+
+	err := Parse(c, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantHosts := []string{"a.com", "b.com", "c.com", "d.com", "e.com"}
+	if !reflect.DeepEqual(c.Hosts, wantHosts) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", wantHosts, c.Hosts)
+	}
+	wantPorts := []int64{80, 443}
+	if !reflect.DeepEqual(c.Ports, wantPorts) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", wantPorts, c.Ports)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	type config struct {
+		Planes  []string
+		Colours []string
+		BaseURL string
+		BaseUrl string
+		Port    int64 `sconfig:"listen"`
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"planes a", config{Planes: []string{"a"}}, ""},
+		{"colours a", config{Colours: []string{"a"}}, ""},
+		{"base-url x", config{BaseUrl: "x"}, ""},
+		{"listen 1", config{Port: 1}, ""},
+		{"colour a", config{}, "unknown option (field Colour is missing)"},
+		{"plane a", config{}, "unknown option (field Plane is missing)"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := ParseWith(&out, f, Options{Strict: true})
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
 // Make sure it doesn't panic.
 func TestWeirdType(t *testing.T) {
 	f := testfile("foo.bar a\nasd.zxc 42\n")
@@ -558,6 +1164,38 @@ func TestFields(t *testing.T) {
 	}
 }
 
+func TestFieldsNested(t *testing.T) {
+	type tls struct{ Cert, Key string }
+	c := struct {
+		Port    int64
+		Server  struct{ TLS tls }
+		Ptr     *tls
+		Nil     *tls
+		Start   time.Time
+		Dur     time.Duration
+		private tls
+	}{Ptr: &tls{Cert: "init"}}
+	names := FieldsNested(&c)
+
+	keys := make([]string, 0, len(names))
+	for k := range names {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	want := []string{"Dur", "Nil", "Port", "Ptr.Cert", "Ptr.Key", "Server.TLS.Cert", "Server.TLS.Key", "Start"}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("\nwant: %#v\nout:  %#v\n", want, keys)
+	}
+
+	if names["Ptr.Cert"].String() != "init" {
+		t.Errorf("wrong value: %v", names["Ptr.Cert"])
+	}
+	names["Server.TLS.Key"].SetString("XXX")
+	if c.Server.TLS.Key != "XXX" {
+		t.Errorf("not set: %#v", c.Server)
+	}
+}
+
 type Marsh struct{ v string }
 
 func (m *Marsh) UnmarshalText(text []byte) error {
@@ -596,4 +1234,2624 @@ func TestTextUnmarshaler(t *testing.T) {
 			t.Errorf("wrong error: %#v", err.Error())
 		}
 	})
+
+	t.Run("slice", func(t *testing.T) {
+		f := testfile("ptr a b\nptr c\nval x y")
+		defer rm(t, f)
+
+		var c struct {
+			Ptr []*Marsh
+			Val []Marsh
+		}
+		err := Parse(&c, f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []*Marsh{{"a"}, {"b"}, {"c"}}
+		if !reflect.DeepEqual(c.Ptr, want) {
+			t.Errorf("wrong: %#v", c.Ptr)
+		}
+		if !reflect.DeepEqual(c.Val, []Marsh{{"x"}, {"y"}}) {
+			t.Errorf("wrong: %#v", c.Val)
+		}
+	})
+
+	t.Run("slice error", func(t *testing.T) {
+		f := testfile("field a\nfield b error c")
+		defer rm(t, f)
+
+		var c struct{ Field []*Marsh }
+		err := Parse(&c, f, nil)
+		if !errorContains(err, "line 2: error parsing field: error") {
+			t.Errorf("wrong error: %v", err)
+		}
+		if !reflect.DeepEqual(c.Field, []*Marsh{{"a"}}) {
+			t.Errorf("wrong: %#v", c.Field)
+		}
+	})
+}
+
+type jsonPoint struct{ X, Y int }
+
+func (p *jsonPoint) UnmarshalJSON(data []byte) error {
+	var v struct{ X, Y int }
+	err := json.Unmarshal(data, &v)
+	p.X, p.Y = v.X, v.Y
+	return err
+}
+
+// Implements both; UnmarshalText() should be used.
+type jsonText struct{ v string }
+
+func (m *jsonText) UnmarshalText(text []byte) error { m.v = "text"; return nil }
+func (m *jsonText) UnmarshalJSON(text []byte) error { m.v = "json"; return nil }
+
+func TestJSONUnmarshaler(t *testing.T) {
+	type config struct {
+		Point jsonPoint
+		Ptr   *jsonPoint
+		Raw   json.RawMessage
+		Both  *jsonText
+		Val   jsonText
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{`point {"x": 1, "y": 2}`, config{Point: jsonPoint{1, 2}}, ""},
+		{`ptr {"x": 1}`, config{Ptr: &jsonPoint{X: 1}}, ""},
+		{`raw ["a",  "b"]`, config{Raw: json.RawMessage(`["a", "b"]`)}, ""},
+		{`both x`, config{Both: &jsonText{"text"}}, ""},
+		{`val x`, config{Val: jsonText{"text"}}, ""},
+		{`point {"x": 1`, config{}, "line 1: error parsing point: unexpected end of JSON input"},
+		{`point x`, config{}, "line 1: error parsing point: invalid character 'x'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestAllowFields(t *testing.T) {
+	type config struct {
+		Name   string
+		Port   int64
+		Secret string
+	}
+
+	tests := []struct {
+		opts    Options
+		in      string
+		want    config
+		wantErr string
+	}{
+		{Options{}, "name x\nsecret y", config{Name: "x", Secret: "y"}, ""},
+		{Options{AllowFields: []string{"Name", "Port"}}, "name x\nport 1", config{Name: "x", Port: 1}, ""},
+		{Options{AllowFields: []string{"Name", "Port"}}, "name x\nsecret y",
+			config{}, "line 2: error parsing secret: setting field Secret is not allowed"},
+		{Options{AllowFields: []string{}}, "name x", config{}, "setting field Name is not allowed"},
+		{Options{DenyFields: []string{"Secret"}}, "name x\nport 1", config{Name: "x", Port: 1}, ""},
+		{Options{DenyFields: []string{"Secret"}}, "secret y",
+			config{}, "line 1: error parsing secret: setting field Secret is not allowed"},
+		{Options{AllowFields: []string{"Name", "Secret"}, DenyFields: []string{"Secret"}}, "secret y",
+			config{}, "setting field Secret is not allowed"},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := ParseWith(&out, f, tc.opts)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && out != tc.want {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestAllowFieldsNested(t *testing.T) {
+	type tls struct{ Cert, Key string }
+	type config struct {
+		Name     string
+		TLS      *tls
+		Database struct{ Host, Password string }
+	}
+
+	tests := []struct {
+		opts    Options
+		in      string
+		want    config
+		wantErr string
+	}{
+		{Options{DenyFields: []string{"TLS"}}, "tls.cert evil",
+			config{}, "line 1: error parsing tls.cert: setting field TLS.Cert is not allowed"},
+		{Options{DenyFields: []string{"Database"}}, "name x\ndatabase.host evil",
+			config{}, "setting field Database.Host is not allowed"},
+		{Options{DenyFields: []string{"Database.Password"}}, "database.host x",
+			config{Database: struct{ Host, Password string }{Host: "x"}}, ""},
+		{Options{DenyFields: []string{"Database.Password"}}, "database.password x",
+			config{}, "setting field Database.Password is not allowed"},
+		{Options{DenyFields: []string{"TL"}}, "tls.cert x", config{TLS: &tls{Cert: "x"}}, ""},
+
+		{Options{AllowFields: []string{"TLS"}}, "tls.cert x\ntls.key y", config{TLS: &tls{Cert: "x", Key: "y"}}, ""},
+		{Options{AllowFields: []string{"TLS.Cert"}}, "tls.cert x", config{TLS: &tls{Cert: "x"}}, ""},
+		{Options{AllowFields: []string{"TLS.Cert"}}, "tls.key x",
+			config{}, "setting field TLS.Key is not allowed"},
+		{Options{AllowFields: []string{"TLS"}}, "database.host x",
+			config{}, "setting field Database.Host is not allowed"},
+		{Options{AllowFields: []string{"TLS"}, DenyFields: []string{"TLS.Key"}}, "tls.key x",
+			config{}, "setting field TLS.Key is not allowed"},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := ParseWith(&out, f, tc.opts)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr != "" {
+				// Nothing should be allocated for denied fields.
+				if out.TLS != nil {
+					t.Errorf("TLS was allocated: %#v", out.TLS)
+				}
+				return
+			}
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestAllowFieldsMap(t *testing.T) {
+	type config struct {
+		Name    string
+		Headers map[string]string
+	}
+
+	tests := []struct {
+		opts    Options
+		in      string
+		want    config
+		wantErr string
+	}{
+		{Options{DenyFields: []string{"Headers"}}, "headers.x evil",
+			config{}, "line 1: error parsing headers.x: setting field Headers.x is not allowed"},
+		{Options{DenyFields: []string{"Headers.x"}}, "headers.x evil",
+			config{}, "setting field Headers.x is not allowed"},
+		{Options{DenyFields: []string{"Headers.x"}}, "headers.y ok",
+			config{Headers: map[string]string{"y": "ok"}}, ""},
+		{Options{AllowFields: []string{"Headers"}}, "headers.x a\nheaders.y b",
+			config{Headers: map[string]string{"x": "a", "y": "b"}}, ""},
+		{Options{AllowFields: []string{"Headers.x"}}, "headers.y b",
+			config{}, "setting field Headers.y is not allowed"},
+		{Options{AllowFields: []string{"Name"}}, "headers.x a",
+			config{}, "setting field Headers.x is not allowed"},
+	}
+
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := ParseWith(&out, f, tc.opts)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr != "" {
+				if out.Headers != nil {
+					t.Errorf("Headers was set: %#v", out.Headers)
+				}
+				return
+			}
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	f := testfile("slow 1\nslow 2\nslow 3\nslow 4\nslow 5\nslow 6")
+	defer rm(t, f)
+
+	var (
+		c    struct{ Slow []string }
+		done []string
+	)
+	handlers := Handlers{
+		"Slow": func(v []string) error {
+			time.Sleep(20 * time.Millisecond)
+			done = append(done, v[0])
+			return nil
+		},
+	}
+
+	err := ParseTimeout(&c, f, handlers, 50*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wrong error: %#v", err)
+	}
+	if len(done) == 0 || len(done) == 6 {
+		t.Errorf("wrong number of lines processed: %v", done)
+	}
+
+	done = nil
+	err = ParseTimeout(&c, f, handlers, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 6 {
+		t.Errorf("wrong number of lines processed: %v", done)
+	}
+}
+
+type testVersion struct{ major, minor int }
+
+func (v testVersion) String() string { return fmt.Sprintf("%d.%d", v.major, v.minor) }
+
+func parseTestVersion(s string) (testVersion, error) {
+	var v testVersion
+	_, err := fmt.Sscanf(s, "%d.%d", &v.major, &v.minor)
+	if err != nil {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	return v, nil
+}
+
+func TestRegisterParseFunc(t *testing.T) {
+	defer func() {
+		delete(typeHandlers, "sconfig.testVersion")
+		delete(typeHandlers, "[]sconfig.testVersion")
+	}()
+
+	RegisterParseFunc(testVersion{}, func(s string) (interface{}, error) {
+		return parseTestVersion(s)
+	})
+
+	type config struct {
+		Version   testVersion
+		Supported []testVersion
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"version 1.2", config{Version: testVersion{1, 2}}, ""},
+		{"supported 1.0 1.1\nsupported 2.0", config{Supported: []testVersion{{1, 0}, {1, 1}, {2, 0}}}, ""},
+		{"version x", config{}, `line 1: error parsing version: invalid version "x"`},
+		{"supported 1.0 x.1", config{}, `invalid version "x.1"`},
+		{"version", config{}, "must have more than 1 values"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+type testMatcher func(string) bool
+
+func TestRegisterConstructors(t *testing.T) {
+	defer func() { delete(typeHandlers, "sconfig.testMatcher") }()
+
+	RegisterConstructors("sconfig.testMatcher", map[string]TypeHandler{
+		"prefix": func(v []string) (interface{}, error) {
+			if len(v) != 1 {
+				return nil, fmt.Errorf("need exactly one prefix, got %d", len(v))
+			}
+			return func(s string) bool { return strings.HasPrefix(s, v[0]) }, nil
+		},
+		"any": func(v []string) (interface{}, error) {
+			return testMatcher(func(string) bool { return true }), nil
+		},
+	})
+
+	tests := []struct {
+		in      string
+		match   []string
+		noMatch []string
+		wantErr string
+	}{
+		{"match prefix /api", []string{"/api", "/api/x"}, []string{"/", "/x/api"}, ""},
+		{"match any", []string{"", "/x"}, nil, ""},
+		{"match prefix", nil, nil, "prefix: need exactly one prefix, got 0"},
+		{"match regexp ^/api", nil, nil, `unknown constructor "regexp" (valid: any, prefix)`},
+		{"match", nil, nil, "must have more than 1 values"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var c struct{ Match testMatcher }
+			err := Parse(&c, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			for _, m := range tc.match {
+				if !c.Match(m) {
+					t.Errorf("%q doesn't match", m)
+				}
+			}
+			for _, m := range tc.noMatch {
+				if c.Match(m) {
+					t.Errorf("%q matches", m)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSection(t *testing.T) {
+	f := testfile(`
+name main
+port 8080
+
+[db]
+host localhost
+port 5432
+
+[ cache ]
+host memcached
+# Comment
+
+[db]
+user db-user
+`)
+	defer rm(t, f)
+
+	type config struct {
+		Name string
+		Host string
+		Port int64
+		User string
+	}
+
+	tests := []struct {
+		section string
+		want    config
+		wantErr string
+	}{
+		{"", config{Name: "main", Port: 8080}, ""},
+		{"db", config{Host: "localhost", Port: 5432, User: "db-user"}, ""},
+		{"cache", config{Host: "memcached"}, ""},
+		{"nope", config{}, `no section "nope"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.section, func(t *testing.T) {
+			var out config
+			err := ParseSection(&out, f, tc.section, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+
+	t.Run("parse", func(t *testing.T) {
+		var out config
+		err := Parse(&out, f, nil)
+		wantErr := "line 5: error parsing [db]: unexpected section header"
+		if !errorContains(err, wantErr) {
+			t.Fatalf("err wrong\nwant: %v\nout:  %v\n", wantErr, err)
+		}
+	})
+}
+
+func TestSliceSep(t *testing.T) {
+	type config struct {
+		Hosts []string
+		Ports []int64
+		Paths []string `sconfig:"sep=:"`
+		Tags  []string `sconfig:"sep=,"`
+		Name  string
+	}
+
+	tests := []struct {
+		in      string
+		sep     string
+		want    config
+		wantErr string
+	}{
+		{"hosts a b", "", config{Hosts: []string{"a", "b"}}, ""},
+		{"hosts a,b, c", ",", config{Hosts: []string{"a", "b", "c"}}, ""},
+		{"hosts a,,b,", ",", config{Hosts: []string{"a", "b"}}, ""},
+		{"hosts a b,c", ",", config{Hosts: []string{"a b", "c"}}, ""},
+		{"hosts a:b\nhosts c", ":", config{Hosts: []string{"a", "b", "c"}}, ""},
+		{"ports 1:2:3", ":", config{Ports: []int64{1, 2, 3}}, ""},
+		{"ports 1:x", ":", config{}, `parsing "x": invalid syntax`},
+		{"name a,b", ",", config{Name: "a,b"}, ""},
+
+		// Tag wins.
+		{"paths /bin:/usr/bin", "", config{Paths: []string{"/bin", "/usr/bin"}}, ""},
+		{"paths /bin:/usr/bin", ",", config{Paths: []string{"/bin", "/usr/bin"}}, ""},
+		{"paths a,b", ":", config{Paths: []string{"a,b"}}, ""},
+		{"tags a,b", ":", config{Tags: []string{"a", "b"}}, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := ParseWith(&out, f, Options{SliceSep: tc.sep})
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestRawLines(t *testing.T) {
+	f := testfile("# Comment\nport   8080  # The port\n\nhosts a\n  b # indented\nhosts c\nname x\\ y\n")
+	defer rm(t, f)
+
+	var c struct {
+		Port  int64
+		Hosts []string
+		Name  string
+		Other string
+	}
+	raw := make(map[string]RawLine)
+	err := ParseWith(&c, f, Options{RawLines: raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]RawLine{
+		"Port":  {Field: "Port", Line: 2, Text: "port   8080  # The port"},
+		"Hosts": {Field: "Hosts", Line: 6, Text: "hosts c"},
+		"Name":  {Field: "Name", Line: 7, Text: `name x\ y`},
+	}
+	if !reflect.DeepEqual(raw, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, raw)
+	}
+
+	f2 := testfile("hosts a\n  b # indented\n\tc")
+	defer rm(t, f2)
+	raw = make(map[string]RawLine)
+	err = ParseWith(&c, f2, Options{RawLines: raw})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := "hosts a\n  b # indented\n\tc"; raw["Hosts"].Text != w {
+		t.Errorf("\nwant: %q\nout:  %q\n", w, raw["Hosts"].Text)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr string
+	}{
+		{"config-version 2\nname x", "x", ""},
+		{"config-version 1\nname x", "x", ""},
+		{"name x", "x", ""},
+		{"name x\nconfig-version 2", "x", ""},
+		{"config-version 3\nname x", "", "line 1: error parsing config-version: unsupported version 3 (supported: 1, 2)"},
+		{"config-version two\nname x", "", `line 1: error parsing config-version: invalid version "two"`},
+		{"config-version\nname x", "", "line 1: error parsing config-version: must have exactly one value"},
+		{"config-version 1 2\nname x", "", "must have exactly one value"},
+
+		// Checked before anything else.
+		{"unknown x\nconfig-version 3", "", "unsupported version 3"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out struct{ Name string }
+			err := ParseWith(&out, f, Options{
+				VersionKey:        "config-version",
+				SupportedVersions: []int{1, 2},
+			})
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if out.Name != tc.want {
+				t.Errorf("\nwant: %q\nout:  %q\n", tc.want, out.Name)
+			}
+		})
+	}
+}
+
+func TestSourceOrder(t *testing.T) {
+	nested := testfile("hosts c1 c2\nnumbers 3")
+	defer rm(t, nested)
+	sourced := testfile(fmt.Sprintf("hosts b\nsource %s\nhosts d\nnumbers 2", nested))
+	defer rm(t, sourced)
+	f := testfile(fmt.Sprintf("hosts a\nnumbers 1\nsource %s\nhosts e\nnumbers 4\nsource %[1]s", sourced))
+	defer rm(t, f)
+
+	var c struct {
+		Hosts   []string
+		Numbers []int64
+	}
+	err := Parse(&c, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantHosts := []string{"a", "b", "c1", "c2", "d", "e", "b", "c1", "c2", "d"}
+	if !reflect.DeepEqual(c.Hosts, wantHosts) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", wantHosts, c.Hosts)
+	}
+	wantNumbers := []int64{1, 3, 2, 4, 3, 2}
+	if !reflect.DeepEqual(c.Numbers, wantNumbers) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", wantNumbers, c.Numbers)
+	}
+}
+
+func TestCommentChars(t *testing.T) {
+	defer func() { CommentChars = []rune{'#'} }()
+
+	tests := []struct {
+		chars []rune
+		in    string
+		want  []Line
+	}{
+		{[]rune{'#'}, "a 1 ; x\n; b 2", []Line{{1, "a 1 ; x"}, {2, "; b 2"}}},
+		{[]rune{'#', ';'}, "a 1 ; x\n; b 2\nc 3 # y\nd \\; \\#", []Line{{1, "a 1"}, {3, "c 3"}, {4, "d ; #"}}},
+		{[]rune{'€'}, "a 1 € x\n€ b\nc \\€ #", []Line{{1, "a 1"}, {3, "c € #"}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.chars), func(t *testing.T) {
+			CommentChars = tc.chars
+			out, err := Scan(strings.NewReader(tc.in), "config")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+
+	t.Run("marshal", func(t *testing.T) {
+		CommentChars = []rune{'#', ';'}
+		out, err := Marshal(struct{ S string }{"a;b#c"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != "s a\\;b\\#c\n" {
+			t.Errorf("wrong: %q", out)
+		}
+	})
+
+	t.Run("options", func(t *testing.T) {
+		CommentChars = []rune{'#'}
+		f := testfile("a 1 ; x\n; b 2\nc 3 # y")
+		defer rm(t, f)
+
+		var c struct{ A, C string }
+		err := ParseWith(&c, f, Options{CommentChars: []rune{';'}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.A != "1" || c.C != "3 # y" {
+			t.Errorf("wrong: %#v", c)
+		}
+	})
+}
+
+func TestScan(t *testing.T) {
+	sourced := testfile("c  3 # Comment")
+	defer rm(t, sourced)
+
+	in := "# Comment\na   1\n\nb 2 # Comment\n    two\nsource " + filepath.Base(sourced) + "\nd\\# 4\n"
+	out, err := Scan(strings.NewReader(in), filepath.Join(filepath.Dir(sourced), "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Line{{2, "a 1"}, {4, "b 2 two"}, {1, "c 3"}, {7, "d# 4"}}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+	}
+
+	_, err = Scan(strings.NewReader("  a"), "config")
+	if !errorContains(err, "first line can't be indented") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestSourceGlob(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "sconfig_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rmAll(t, dir)
+
+	for name, data := range map[string]string{
+		"20-b.conf": "hosts b",
+		"10-a.conf": "hosts a1\nhosts a2",
+		"30-c.conf": "hosts c",
+		"other":     "hosts x",
+	} {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		in      string
+		want    []string
+		wantErr string
+	}{
+		{"source %s/*.conf\nhosts d", []string{"a1", "a2", "b", "c", "d"}, ""},
+		{"source %s/[23]0-?.conf", []string{"b", "c"}, ""},
+		{"source %s/other", []string{"x"}, ""},
+		{"source %s/*.nope", nil, "*.nope: no files match the pattern"},
+		{"source %s/[", nil, "syntax error in pattern"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(fmt.Sprintf(tc.in, dir))
+			defer rm(t, f)
+
+			var c struct{ Hosts []string }
+			err := Parse(&c, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(c.Hosts, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, c.Hosts)
+			}
+		})
+	}
+}
+
+func TestSourceMissingPath(t *testing.T) {
+	for _, in := range []string{"source", "source # x", "source   ", "source \t # x", "port 1\nsource#x"} {
+		t.Run(in, func(t *testing.T) {
+			f := testfile(in)
+			defer rm(t, f)
+
+			var c struct{ Port int64 }
+			err := Parse(&c, f, nil)
+			if !errorContains(err, "source: missing path") {
+				t.Errorf("wrong error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSourceKeyword(t *testing.T) {
+	defer func() { SourceKeyword = "source" }()
+
+	inc := testfile("hosts b")
+	defer rm(t, inc)
+
+	tests := []struct {
+		keyword string
+		in      string
+		want    []string
+		source  string
+	}{
+		{"source", "hosts a\nsource " + inc, []string{"a", "b"}, ""},
+		{"source", "hosts a\n\\source " + inc, []string{"a"}, inc},
+		{"source", "hosts a\nsource\t" + inc, []string{"a", "b"}, ""},
+		{"include", "hosts a\ninclude " + inc + "\nsource x", []string{"a", "b"}, "x"},
+		{"include", "\\include x", nil, ""},
+		{"", "hosts a\nsource x", []string{"a"}, "x"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.keyword+" "+tc.in, func(t *testing.T) {
+			SourceKeyword = tc.keyword
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var c struct {
+				Hosts  []string
+				Source string
+			}
+			err := Parse(&c, f, nil)
+			if tc.want == nil {
+				if !errorContains(err, "unknown option") {
+					t.Fatalf("wrong error: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c.Hosts, tc.want) || c.Source != tc.source {
+				t.Errorf("wrong: %#v", c)
+			}
+		})
+	}
+}
+
+func TestSourceKeywordOptions(t *testing.T) {
+	inc2 := testfile("hosts c")
+	defer rm(t, inc2)
+	inc := testfile("hosts b\ninclude " + inc2)
+	defer rm(t, inc)
+
+	tests := []struct {
+		keyword string
+		in      string
+		want    []string
+		source  string
+	}{
+		{"", "hosts a\nsource " + inc2, []string{"a", "c"}, ""},
+		{"include", "hosts a\ninclude " + inc + "\nsource x", []string{"a", "b", "c"}, "x"},
+		{"-", "hosts a\nsource x", []string{"a"}, "x"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.keyword+" "+tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var c struct {
+				Hosts  []string
+				Source string
+			}
+			err := ParseWith(&c, f, Options{SourceKeyword: tc.keyword})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(c.Hosts, tc.want) || c.Source != tc.source {
+				t.Errorf("wrong: %#v", c)
+			}
+			if SourceKeyword != "source" {
+				t.Errorf("SourceKeyword changed to %q", SourceKeyword)
+			}
+		})
+	}
+}
+
+func TestSourceRelative(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "sconfig_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rmAll(t, dir)
+
+	err = os.Mkdir(filepath.Join(dir, "conf.d"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"app.conf":        "hosts a\nsource ./extra.conf\nsource conf.d/*.conf",
+		"extra.conf":      "hosts b",
+		"conf.d/1.conf":   "hosts c\nsource ../last.conf",
+		"last.conf":       "hosts d",
+		"absolute.conf":   "source " + filepath.Join(dir, "extra.conf"),
+		"conf.d/skip.txt": "hosts x",
+	} {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	err = os.Chdir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var c struct{ Hosts []string }
+	err = Parse(&c, filepath.Join(dir, "app.conf"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(c.Hosts, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, c.Hosts)
+	}
+
+	c.Hosts = nil
+	err = Parse(&c, filepath.Join(dir, "absolute.conf"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"b"}; !reflect.DeepEqual(c.Hosts, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, c.Hosts)
+	}
+}
+
+func TestSourceLoop(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "sconfig_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rmAll(t, dir)
+
+	write := func(name, data string) string {
+		p := filepath.Join(dir, name)
+		err := ioutil.WriteFile(p, []byte(data), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	a, b := filepath.Join(dir, "a.conf"), filepath.Join(dir, "b.conf")
+	write("a.conf", "hosts a\nsource "+b)
+	write("b.conf", "hosts b\nsource "+filepath.Join(dir, "..", filepath.Base(dir), "a.conf"))
+	self := write("self.conf", "source "+filepath.Join(dir, "self.conf"))
+	err = os.Symlink(self, filepath.Join(dir, "link.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	link := write("link-self.conf", "source "+filepath.Join(dir, "link.conf"))
+	// Sourcing the same file twice is fine.
+	twice := write("twice.conf", "source "+filepath.Join(dir, "c.conf")+"\nsource "+filepath.Join(dir, "c.conf"))
+	write("c.conf", "hosts c")
+
+	tests := []struct {
+		in      string
+		wantErr string
+	}{
+		{a, "source loop detected: " + a + " -> " + b + " -> " + filepath.Join(dir, "..", filepath.Base(dir), "a.conf")},
+		{self, "source loop detected: " + self + " -> " + self},
+		{link, "source loop detected: " + filepath.Join(dir, "link.conf") + " -> " + self},
+		{twice, ""},
+	}
+	for _, tc := range tests {
+		t.Run(filepath.Base(tc.in), func(t *testing.T) {
+			var c struct{ Hosts []string }
+			err := Parse(&c, tc.in, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+		})
+	}
+
+	t.Run("depth", func(t *testing.T) {
+		write("deep-101.conf", "hosts x")
+		for i := 100; i >= 0; i-- {
+			write(fmt.Sprintf("deep-%d.conf", i), fmt.Sprintf("source %s/deep-%d.conf", dir, i+1))
+		}
+
+		var c struct{ Hosts []string }
+		err := Parse(&c, filepath.Join(dir, "deep-1.conf"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Parse(&c, filepath.Join(dir, "deep-0.conf"), nil)
+		if !errorContains(err, "deep-101.conf: too many nested source lines (max: 100)") {
+			t.Errorf("wrong error: %v", err)
+		}
+	})
+}
+
+func TestStrictTags(t *testing.T) {
+	type config struct {
+		BaseURL string   `sconfig:"base-url"`
+		Hosts   []string `sconfig:"host"`
+		Port    int64    `sconfig:",sep=:"`
+		Name    string
+	}
+
+	tests := []struct {
+		in      string
+		strict  bool
+		want    config
+		wantErr string
+	}{
+		{"base-url x\nhost a\nhost b", true, config{BaseURL: "x", Hosts: []string{"a", "b"}}, ""},
+		{"base-url x\nhost a\nhost b", false, config{BaseURL: "x", Hosts: []string{"a", "b"}}, ""},
+		{"hosts a", false, config{Hosts: []string{"a"}}, ""},
+		{"name x", false, config{Name: "x"}, ""},
+
+		// Inference would match these.
+		{"hosts a", true, config{}, `unknown option (no field with the tag sconfig:"hosts")`},
+		{"BaseURL x", true, config{}, `no field with the tag sconfig:"BaseURL"`},
+		{"base-urls x", true, config{}, `no field with the tag sconfig:"base-urls"`},
+		{"name x", true, config{}, `no field with the tag sconfig:"name"`},
+		{"port 1", true, config{}, `no field with the tag sconfig:"port"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%s %t", tc.in, tc.strict), func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := ParseWith(&out, f, Options{StrictTags: tc.strict})
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestPairs(t *testing.T) {
+	f := testfile("use auth logging\nuse ratelimit=10 auth\nheader z=1 a=2")
+	defer rm(t, f)
+
+	var c struct {
+		Use     []Pair
+		Headers []Pair
+	}
+	err := Parse(&c, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantUse := []Pair{{"auth", ""}, {"logging", ""}, {"ratelimit", "10"}, {"auth", ""}}
+	if !reflect.DeepEqual(c.Use, wantUse) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", wantUse, c.Use)
+	}
+	wantHeaders := []Pair{{"z", "1"}, {"a", "2"}}
+	if !reflect.DeepEqual(c.Headers, wantHeaders) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", wantHeaders, c.Headers)
+	}
+}
+
+func TestNestedPointer(t *testing.T) {
+	type tlsConfig struct {
+		Cert, Key string
+	}
+	type config struct {
+		Port int64
+		TLS  *tlsConfig
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"port 1", config{Port: 1}, ""},
+		{"tls.cert a.pem\ntls.key a.key", config{TLS: &tlsConfig{Cert: "a.pem", Key: "a.key"}}, ""},
+		{"tls.cert a.pem", config{TLS: &tlsConfig{Cert: "a.pem"}}, ""},
+		{"TLS.Key a.key", config{TLS: &tlsConfig{Key: "a.key"}}, ""},
+		{"tls.nope x", config{}, "unknown option (field Nope or Nopes is missing)"},
+		{"port.x 1", config{}, "Port is not a struct"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+
+	t.Run("handler", func(t *testing.T) {
+		f := testfile("tls.cert a.pem")
+		defer rm(t, f)
+
+		var (
+			out config
+			got []string
+		)
+		err := Parse(&out, f, Handlers{"TLS.Cert": func(v []string) error {
+			got = v
+			return nil
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, []string{"a.pem"}) {
+			t.Errorf("wrong: %#v", got)
+		}
+	})
+}
+
+func TestNestedStruct(t *testing.T) {
+	type httpConfig struct {
+		BaseURL string
+		Port    int64
+	}
+	type serverConfig struct {
+		HTTP    httpConfig
+		Workers int64
+	}
+	type config struct {
+		Server serverConfig
+	}
+
+	f := testfile("server.workers 4\nserver.http.base-url http://example.com\nserver.http.port 8080")
+	defer rm(t, f)
+
+	var out config
+	err := Parse(&out, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config{Server: serverConfig{Workers: 4, HTTP: httpConfig{BaseURL: "http://example.com", Port: 8080}}}
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+	}
+
+	f2 := testfile("server.workers.x 4")
+	defer rm(t, f2)
+	err = Parse(&out, f2, nil)
+	if !errorContains(err, "error parsing server.workers.x: Server.Workers is not a struct") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestMapKeys(t *testing.T) {
+	type config struct {
+		Cache   map[time.Duration]int64
+		Groups  map[string][]string
+		Weights map[int64]float64
+		Array   map[[2]int64]string
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"cache.1m 100\ncache.5m 500", config{Cache: map[time.Duration]int64{
+			time.Minute: 100, 5 * time.Minute: 500}}, ""},
+		{"cache.1m 100\ncache.60s 200", config{Cache: map[time.Duration]int64{
+			time.Minute: 200}}, ""},
+		{"groups.admin a b\ngroups.user c\ngroups.admin d", config{Groups: map[string][]string{
+			"admin": {"a", "b", "d"}, "user": {"c"}}}, ""},
+		{"groups.a.b x", config{Groups: map[string][]string{"a.b": {"x"}}}, ""},
+		{"weights.+2 0.5", config{Weights: map[int64]float64{2: 0.5}}, ""},
+
+		{"cache.1x 100", config{}, `invalid map key "1x"`},
+		{"cache.1m x", config{}, `parsing "x": invalid syntax`},
+		{"weights.a 1", config{}, `invalid map key "a"`},
+		{"array.a x", config{}, "don't know how to set map keys of the type [2]int64"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestRows(t *testing.T) {
+	type config struct {
+		Row    [][]string
+		Matrix [][]int64
+		Flat   []string
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"row a b c\nrow d e", config{Row: [][]string{{"a", "b", "c"}, {"d", "e"}}}, ""},
+		{"row a\n  b\nrow c", config{Row: [][]string{{"a", "b"}, {"c"}}}, ""},
+		{"flat a b c\nflat d e", config{Flat: []string{"a", "b", "c", "d", "e"}}, ""},
+		{"matrix 1 2\nmatrix 3 4", config{Matrix: [][]int64{{1, 2}, {3, 4}}}, ""},
+
+		{"matrix 1 x", config{}, `parsing "x": invalid syntax`},
+		{"row", config{}, "must have more than 1 values (has: 0)"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestStructSlice(t *testing.T) {
+	type server struct {
+		Name    string
+		Port    int64
+		private string
+		Timeout time.Duration
+	}
+	type config struct {
+		Servers []server
+		Ptrs    []*server
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"server web1 8080 1s\nserver web2 8081 2s", config{Servers: []server{
+			{Name: "web1", Port: 8080, Timeout: time.Second},
+			{Name: "web2", Port: 8081, Timeout: 2 * time.Second},
+		}}, ""},
+		{"ptr web1 8080 1s", config{Ptrs: []*server{{Name: "web1", Port: 8080, Timeout: time.Second}}}, ""},
+		{"server web1\n  8080 1s", config{Servers: []server{{Name: "web1", Port: 8080, Timeout: time.Second}}}, ""},
+
+		{"server web1 8080", config{}, "must have 3 values for the fields Name, Port, Timeout (has: 2)"},
+		{"server web1 8080 1s x", config{}, "must have 3 values for the fields Name, Port, Timeout (has: 4)"},
+		{"server web1 x 1s", config{}, `Port: strconv.ParseInt: parsing "x": invalid syntax`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestPointers(t *testing.T) {
+	type config struct {
+		Int    *int64
+		Bool   *bool
+		Str    *string
+		Ints   []*int64
+		Strs   []*string `sconfig:",len=3,fill=x"`
+		Struct *struct{ X int64 }
+	}
+
+	f := testfile("int 42\nbool no\nstr hello world\nints 1 2\nints 3\nstrs a\nstruct.x 1")
+	defer rm(t, f)
+
+	var out config
+	err := Parse(&out, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Int == nil || *out.Int != 42 || out.Bool == nil || *out.Bool ||
+		out.Str == nil || *out.Str != "hello world" || out.Struct.X != 1 {
+		t.Errorf("wrong: %#v", out)
+	}
+	var ints []int64
+	for _, i := range out.Ints {
+		ints = append(ints, *i)
+	}
+	if !reflect.DeepEqual(ints, []int64{1, 2, 3}) {
+		t.Errorf("wrong ints: %v", ints)
+	}
+	var strs []string
+	for _, s := range out.Strs {
+		strs = append(strs, *s)
+	}
+	if !reflect.DeepEqual(strs, []string{"a", "x", "x"}) {
+		t.Errorf("wrong strs: %v", strs)
+	}
+
+	t.Run("reuse", func(t *testing.T) {
+		f := testfile("int 2")
+		defer rm(t, f)
+
+		i := int64(1)
+		out := config{Int: &i}
+		err := Parse(&out, f, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Int != &i || i != 2 {
+			t.Errorf("pointer not re-used: %v %v", out.Int, i)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		f := testfile("int x")
+		defer rm(t, f)
+
+		var out config
+		err := Parse(&out, f, nil)
+		if !errorContains(err, `parsing "x": invalid syntax`) {
+			t.Errorf("wrong error: %v", err)
+		}
+		if out.Int != nil {
+			t.Errorf("allocated on error: %v", out.Int)
+		}
+	})
+}
+
+func TestFieldHandlers(t *testing.T) {
+	type config struct {
+		Hostname string
+		Name     string
+		Ports    []int64
+		TLS      struct{ Cert string }
+	}
+
+	f := testfile("hostname Example.COM\nname Example.COM\nports 80\nports 443\ntls.cert X.pem")
+	defer rm(t, f)
+
+	lower := func(v []string) (interface{}, error) {
+		return strings.ToLower(strings.Join(v, " ")), nil
+	}
+	var out config
+	err := ParseWith(&out, f, Options{FieldHandlers: map[string]TypeHandler{
+		"Hostname": lower,
+		"TLS.Cert": lower,
+		"Ports": func(v []string) (interface{}, error) {
+			return []int64{int64(len(v[0]))}, nil
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config{Hostname: "example.com", Name: "Example.COM", Ports: []int64{2, 3}}
+	want.TLS.Cert = "x.pem"
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+	}
+
+	err = ParseWith(&out, f, Options{FieldHandlers: map[string]TypeHandler{
+		"Hostname": func(v []string) (interface{}, error) { return 1, nil },
+	}})
+	if !errorContains(err, "error parsing hostname: handler returned int for a field of the type string") {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	err = ParseWith(&out, f, Options{FieldHandlers: map[string]TypeHandler{
+		"Name": func(v []string) (interface{}, error) { return nil, errors.New("oh noes") },
+	}})
+	if !errorContains(err, "line 2: error parsing name: oh noes") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestDefaultTag(t *testing.T) {
+	type config struct {
+		Port    int64         `default:"8080"`
+		Hosts   []string      `default:"a b"`
+		Timeout time.Duration `default:"5s"`
+		Debug   bool          `default:"yes"`
+		Name    string        `default:"hello world"`
+		TLS     struct {
+			Cert string `default:"cert.pem"`
+		}
+	}
+
+	tests := []struct {
+		in      string
+		pre     config
+		want    func(*config)
+		wantErr string
+	}{
+		{"", config{}, func(c *config) {}, ""},
+		{"port 80\nhosts x\ndebug no", config{}, func(c *config) {
+			c.Port, c.Hosts, c.Debug = 80, []string{"x"}, false
+		}, ""},
+		{"port 0\ntls.cert x", config{}, func(c *config) { c.Port, c.TLS.Cert = 0, "x" }, ""},
+		{"", config{Port: 1, Name: "pre"}, func(c *config) { c.Port, c.Name = 1, "pre" }, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			want := config{Port: 8080, Hosts: []string{"a", "b"}, Timeout: 5 * time.Second,
+				Debug: true, Name: "hello world"}
+			want.TLS.Cert = "cert.pem"
+			tc.want(&want)
+
+			out := tc.pre
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if !reflect.DeepEqual(out, want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		f := testfile("")
+		defer rm(t, f)
+
+		var out struct {
+			Port int64 `default:"x"`
+		}
+		err := Parse(&out, f, nil)
+		if !errorContains(err, `invalid default "x" for Port: strconv.ParseInt: parsing "x": invalid syntax`) {
+			t.Errorf("wrong error: %v", err)
+		}
+		err = SetDefaults(&out)
+		if !errorContains(err, `sconfig.SetDefaults: invalid default "x" for Port`) {
+			t.Errorf("wrong error: %v", err)
+		}
+		err = SetDefaults(out)
+		if !errorContains(err, "must be a pointer to a struct") {
+			t.Errorf("wrong error: %v", err)
+		}
+	})
+
+	t.Run("layered", func(t *testing.T) {
+		f1 := testfile("enabled false\nport 1")
+		defer rm(t, f1)
+		f2 := testfile("name x")
+		defer rm(t, f2)
+
+		type config struct {
+			Enabled bool   `default:"true"`
+			Port    int64  `default:"8080"`
+			Name    string `default:"y"`
+			Debug   bool   `default:"true"`
+		}
+
+		// Parse() sets the default again.
+		var out config
+		for _, f := range []string{f1, f2} {
+			if err := Parse(&out, f, nil); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if want := (config{true, 1, "x", true}); out != want {
+			t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+		}
+
+		out = config{}
+		if err := SetDefaults(&out); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []string{f1, f2} {
+			if err := ParseWith(&out, f, Options{NoDefaults: true}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if want := (config{false, 1, "x", true}); out != want {
+			t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+		}
+	})
+}
+
+func TestRequired(t *testing.T) {
+	type config struct {
+		Port    int64  `sconfig:",required"`
+		BaseURL string `sconfig:",required"`
+		Special string `sconfig:",required"`
+		Opt     string
+		TLS     *struct {
+			Cert string `sconfig:",required"`
+			Key  string
+		}
+	}
+
+	tests := []struct {
+		in      string
+		wantErr string
+		missing []string
+	}{
+		{"port 0\nbase-url x\nspecial y", "", nil},
+		{"port 1\nspecial y", "missing required options: BaseURL", []string{"BaseURL"}},
+		{"opt x", "missing required options: Port, BaseURL, Special", []string{"Port", "BaseURL", "Special"}},
+		{"port 1\nbase-url x\nspecial y\ntls.key x", "missing required options: TLS.Cert", []string{"TLS.Cert"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, Handlers{"Special": func([]string) error { return nil }})
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+
+			var reqErr *RequiredError
+			if errors.As(err, &reqErr) != (tc.missing != nil) {
+				t.Fatalf("wrong error type: %T", err)
+			}
+			if reqErr != nil && !reflect.DeepEqual(reqErr.Fields, tc.missing) {
+				t.Errorf("wrong fields: %v", reqErr.Fields)
+			}
+		})
+	}
+}
+
+func TestTagName(t *testing.T) {
+	type config struct {
+		Endpoint string `sconfig:"api-base"`
+		APIBase  string
+		Host     string `sconfig:"server"`
+		Nested   struct {
+			Value string `sconfig:"v"`
+		}
+	}
+
+	f := testfile("api-base http://example.com\nserver example.com\nhost x\nnested.v 1")
+	defer rm(t, f)
+
+	var out config
+	err := Parse(&out, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config{Endpoint: "http://example.com", Host: "x"}
+	want.Nested.Value = "1"
+	if !reflect.DeepEqual(out, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+	}
+}
+
+func TestMapFields(t *testing.T) {
+	type config struct {
+		Header map[string]string
+		Allow  map[string][]string
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"header Content-Type text/html\nheader X-Frame deny a b", config{Header: map[string]string{
+			"Content-Type": "text/html", "X-Frame": "deny", "a": "b"}}, ""},
+		{"header a 1\nheader a 2", config{Header: map[string]string{"a": "2"}}, ""},
+		{"allow GET /a /b\nallow POST /c\nallow GET /d", config{Allow: map[string][]string{
+			"GET": {"/a", "/b", "/d"}, "POST": {"/c"}}}, ""},
+		{"allow.GET /a\nallow GET /b", config{Allow: map[string][]string{"GET": {"/a", "/b"}}}, ""},
+
+		{"header a b c", config{}, "uneven number of arguments: 3"},
+		{"header a", config{}, "must have more than 2 values (has: 1)"},
+		{"allow GET", config{}, "must have more than 2 values (has: 1)"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestFoldKeys(t *testing.T) {
+	type config struct {
+		BaseURL string
+		Hosts   []string
+		UserID  int64
+		UserId  int64
+		TLS     struct{ CertFile string }
+	}
+
+	tests := []struct {
+		in      string
+		fold    bool
+		want    config
+		wantErr string
+	}{
+		{"base-url x", false, config{BaseURL: "x"}, ""},
+		{"baseurl x", false, config{}, "unknown option"},
+		{"base_url x", true, config{BaseURL: "x"}, ""},
+		{"BaseUrl x", true, config{BaseURL: "x"}, ""},
+		{"baseurl x", true, config{BaseURL: "x"}, ""},
+		{"BASE-URL x", true, config{BaseURL: "x"}, ""},
+		{"host a\nHOST b", true, config{Hosts: []string{"a", "b"}}, ""},
+		{"tls.cert_file x", true, config{TLS: struct{ CertFile string }{"x"}}, ""},
+		{"user-id 1", true, config{UserID: 1}, ""},
+		{"userid 1", true, config{}, "ambiguous option (matches the fields UserID, UserId)"},
+		{"nope x", true, config{}, "unknown option (field Nope or Nopes is missing)"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := ParseWith(&out, f, Options{FoldKeys: tc.fold})
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestIgnoreUnknown(t *testing.T) {
+	type config struct {
+		Port int64
+		TLS  struct{ Cert string }
+		Host string `sconfig:"hostname"`
+	}
+
+	tests := []struct {
+		in          string
+		opts        Options
+		want        config
+		wantUnknown []string
+		wantErr     string
+	}{
+		{"port 1\nnope x", Options{}, config{}, nil, "unknown option (field Nope or Nopes is missing)"},
+		{"port 1\nnope x\ntls.key y\nport 2", Options{IgnoreUnknown: true},
+			config{Port: 2}, []string{"nope", "tls.key"}, ""},
+		{"hostname x\nhost y", Options{IgnoreUnknown: true, StrictTags: true},
+			config{Host: "x"}, []string{"host"}, ""},
+
+		// Known fields with invalid values are still an error.
+		{"nope x\nport x", Options{IgnoreUnknown: true}, config{}, nil, `parsing "x": invalid syntax`},
+		{"port.x 1", Options{IgnoreUnknown: true}, config{}, nil, "Port is not a struct"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var (
+				out     config
+				unknown []string
+				unused  []Line
+			)
+			tc.opts.Unknown, tc.opts.Unused = &unknown, &unused
+			err := ParseWith(&out, f, tc.opts)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr != "" {
+				return
+			}
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+			if !reflect.DeepEqual(unknown, tc.wantUnknown) {
+				t.Errorf("unknown\nwant: %#v\nout:  %#v\n", tc.wantUnknown, unknown)
+			}
+			if len(unused) != len(unknown) {
+				t.Fatalf("unused has %d lines, unknown has %d", len(unused), len(unknown))
+			}
+			for i := range unused {
+				if !strings.HasPrefix(unused[i].Text, unknown[i]+" ") {
+					t.Errorf("unused %d: %#v", i, unused[i])
+				}
+			}
+		})
+	}
+}
+
+// Run with -race to detect problems.
+func TestRegisterTypeConcurrent(t *testing.T) {
+	defer delete(typeHandlers, "int32")
+
+	f := testfile("port 8080\nhosts a b")
+	defer rm(t, f)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			RegisterType("int32", HandleRune)
+		}()
+		go func() {
+			defer wg.Done()
+			var c struct {
+				Port  int64
+				Hosts []string
+			}
+			if err := Parse(&c, f, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestUnused(t *testing.T) {
+	f := testfile("# Comment\nport 1\n\nold-port  2 # Deprecated\nhost x\n  y")
+	defer rm(t, f)
+
+	var (
+		c      struct{ Port int64 }
+		unused []Line
+	)
+	err := ParseWith(&c, f, Options{IgnoreUnknown: true, Unused: &unused})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Line{{4, "old-port 2"}, {5, "host x y"}}
+	if !reflect.DeepEqual(unused, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, unused)
+	}
+}
+
+func TestHandlersCtx(t *testing.T) {
+	f := testfile("port 1\n\nbase-url x\nhost y")
+	defer rm(t, f)
+
+	var (
+		c   struct{ Port, BaseURL, Host string }
+		got []HandlerContext
+	)
+	err := ParseWith(&c, f, Options{
+		Handlers: Handlers{
+			"Host": func(v []string) error { return nil },
+		},
+		HandlersCtx: map[string]HandlerCtx{
+			"BaseURL": func(ctx HandlerContext, v []string) error {
+				got = append(got, ctx)
+				return nil
+			},
+			"Port": func(ctx HandlerContext, v []string) error {
+				got = append(got, ctx)
+				return nil
+			},
+			"Host": func(ctx HandlerContext, v []string) error {
+				t.Error("HandlersCtx used instead of Handlers")
+				return nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HandlerContext{{f, 1, "port"}, {f, 3, "base-url"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, got)
+	}
+	if c != (struct{ Port, BaseURL, Host string }{}) {
+		t.Errorf("field set: %#v", c)
+	}
+
+	err = ParseWith(&c, f, Options{
+		HandlersCtx: map[string]HandlerCtx{
+			"BaseURL": func(ctx HandlerContext, v []string) error {
+				return fmt.Errorf("invalid %s on line %d", ctx.Key, ctx.Line)
+			},
+		},
+	})
+	if !errorContains(err, "line 3: error parsing base-url: invalid base-url on line 3 (from handler)") {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	t.Run("source", func(t *testing.T) {
+		inc := testfile("\n\n\nport 2")
+		defer rm(t, inc)
+		f := testfile("port 1\nsource " + inc + "\nport 3")
+		defer rm(t, f)
+
+		var got []HandlerContext
+		err := ParseWith(&c, f, Options{
+			HandlersCtx: map[string]HandlerCtx{
+				"Port": func(ctx HandlerContext, v []string) error {
+					got = append(got, ctx)
+					return nil
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []HandlerContext{{f, 1, "port"}, {inc, 4, "port"}, {f, 3, "port"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("\nwant: %#v\nout:  %#v\n", want, got)
+		}
+	})
+}
+
+func TestMultiMap(t *testing.T) {
+	f := testfile("header.accept json\nheader.x-frame deny\nheader.accept xml html\n" +
+		"header.accept\n    text # Continuation\n")
+	defer rm(t, f)
+
+	var out struct {
+		Header map[string][]string
+	}
+	err := Parse(&out, f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]string{
+		"accept":  {"json", "xml", "html", "text"},
+		"x-frame": {"deny"},
+	}
+	if !reflect.DeepEqual(out.Header, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, out.Header)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	// Simple NFC normalization for just the characters in this test.
+	nfc := strings.NewReplacer("e\u0301", "\u00e9", "u\u0308", "\u00fc").Replace
+
+	type config struct {
+		Café  string
+		Names []string
+	}
+	nfdFile := testfile("caf\u0065\u0301 cr\u0065\u0301me\nnames M\u0075\u0308ller Jos\u0065\u0301")
+	defer rm(t, nfdFile)
+	nfcFile := testfile("caf\u00e9 cr\u00e9me\nnames M\u00fcller Jos\u00e9")
+	defer rm(t, nfcFile)
+
+	var nfd, nfc2 config
+	err := ParseWith(&nfd, nfdFile, Options{Normalize: nfc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ParseWith(&nfc2, nfcFile, Options{Normalize: nfc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nfd, nfc2) {
+		t.Errorf("\nNFD: %#v\nNFC: %#v", nfd, nfc2)
+	}
+	if want := []string{"M\u00fcller", "Jos\u00e9"}; !reflect.DeepEqual(nfd.Names, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v\n", want, nfd.Names)
+	}
+
+	// Not normalized by default.
+	err = Parse(&nfd, nfdFile, nil)
+	if !errorContains(err, "unknown option") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestDedupMax(t *testing.T) {
+	type config struct {
+		Labels  []string `sconfig:",dedup"`
+		Tags    []string `sconfig:",dedup,max=3"`
+		Hosts   []string `sconfig:",max=2"`
+		Numbers []int64  `sconfig:",dedup"`
+		Caps    [][]byte `sconfig:",dedup"`
+		Bad     []string `sconfig:",max=x"`
+	}
+	RegisterType("[][]uint8", func(v []string) (interface{}, error) {
+		r := make([][]byte, len(v))
+		for i := range v {
+			r[i] = []byte(v[i])
+		}
+		return r, nil
+	})
+	defer delete(typeHandlers, "[][]uint8")
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"labels b a b c a", config{Labels: []string{"b", "a", "c"}}, ""},
+		{"labels b a\nlabels c a b d", config{Labels: []string{"b", "a", "c", "d"}}, ""},
+		{"numbers 1 2 1 3 2", config{Numbers: []int64{1, 2, 3}}, ""},
+		{"caps a b a", config{Caps: [][]byte{[]byte("a"), []byte("b")}}, ""},
+
+		{"tags a b a c b", config{Tags: []string{"a", "b", "c"}}, ""},
+		{"tags a b\ntags c c a", config{Tags: []string{"a", "b", "c"}}, ""},
+		{"tags a b c d", config{}, "too many unique values: 4 (max: 3)"},
+		{"tags a b\ntags c d", config{}, "line 2: error parsing tags: too many unique values: 4 (max: 3)"},
+
+		{"hosts a a", config{Hosts: []string{"a", "a"}}, ""},
+		{"hosts a a a", config{}, "too many values: 3 (max: 2)"},
+		{"bad a", config{}, `invalid max "x" in struct tag`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestCount(t *testing.T) {
+	type config struct {
+		Verbose int64  `sconfig:",count"`
+		Debug   uint64 `sconfig:",count"`
+		Int64   int64
+		Name    string `sconfig:",count"`
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"verbose", config{Verbose: 1}, ""},
+		{"verbose\nverbose\nverbose", config{Verbose: 3}, ""},
+		{"debug\ndebug", config{Debug: 2}, ""},
+		{"verbose\nverbose 5\nverbose", config{Verbose: 6}, ""},
+		{"verbose 2", config{Verbose: 2}, ""},
+		{"verbose x", config{}, `parsing "x": invalid syntax`},
+		{"int64", config{}, "must have exactly one value"},
+		{"name", config{}, "the count option can't be used on fields of the type string"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	i := reflect.New(reflect.TypeOf(int64(0))).Elem()
+	has, err := SetValue(i, []string{"42"})
+	if !has || err != nil {
+		t.Fatalf("has: %t; err: %v", has, err)
+	}
+	if i.Int() != 42 {
+		t.Errorf("wrong: %d", i.Int())
+	}
+
+	var s []string
+	sv := reflect.ValueOf(&s).Elem()
+	for _, v := range [][]string{{"a", "b"}, {"c"}} {
+		if _, err := SetValue(sv, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(s, []string{"a", "b", "c"}) {
+		t.Errorf("wrong: %#v", s)
+	}
+
+	d := reflect.New(reflect.TypeOf(time.Duration(0))).Elem()
+	_, err = SetValue(d, []string{"x"})
+	if !errorContains(err, `unable to parse "x" as a duration`) {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	has, err = SetValue(reflect.New(reflect.TypeOf(uintptr(0))).Elem(), []string{"1"})
+	if has || err != nil {
+		t.Errorf("has: %t; err: %v", has, err)
+	}
+
+	_, err = SetValue(reflect.ValueOf(int64(1)), []string{"1"})
+	if !errorContains(err, "value of type int64 is not settable") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestIntern(t *testing.T) {
+	f := testfile("name a\nhosts a b a\nhosts b c\nother x")
+	defer rm(t, f)
+
+	type config struct {
+		Name  string
+		Hosts []string
+		Other string
+	}
+	var plain, interned config
+	if err := Parse(&plain, f, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ParseWith(&interned, f, Options{Intern: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plain, interned) {
+		t.Errorf("\nplain:    %#v\ninterned: %#v", plain, interned)
+	}
+}
+
+func BenchmarkIntern(b *testing.B) {
+	var data strings.Builder
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&data, "hosts host-%d.example.com host-%d.example.com\n", i%10, (i+1)%10)
+	}
+	f := testfile(data.String())
+	defer os.Remove(f)
+
+	for _, in := range []bool{false, true} {
+		b.Run(fmt.Sprintf("intern=%t", in), func(b *testing.B) {
+			b.ReportAllocs()
+			for n := 0; n < b.N; n++ {
+				var c struct{ Hosts []string }
+				if err := ParseWith(&c, f, Options{Intern: in}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+type testLevel int
+
+func (l *testLevel) Set(s string) error {
+	switch s {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("invalid level %q", s)
+	}
+	return nil
+}
+
+func TestSetter(t *testing.T) {
+	type config struct {
+		Level    testLevel
+		LevelPtr *testLevel
+		Levels   []testLevel
+	}
+	ptr := func(l testLevel) *testLevel { return &l }
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"level high", config{Level: 2}, ""},
+		{"level-ptr low", config{LevelPtr: ptr(1)}, ""},
+		{"levels low high\nlevels low", config{Levels: []testLevel{1, 2, 1}}, ""},
+		{"level x", config{}, `invalid level "x"`},
+		{"level low high", config{}, `invalid level "low high"`},
+		{"levels low x", config{}, `invalid level "x"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestMaxErrors(t *testing.T) {
+	var data strings.Builder
+	data.WriteString("int64 1\n")
+	for i := 0; i < 50; i++ {
+		data.WriteString("int64 x\n")
+	}
+	data.WriteString("str last\n")
+	f := testfile(data.String())
+	defer rm(t, f)
+
+	tests := []struct {
+		max      int
+		wantLen  int
+		wantMore string
+	}{
+		{0, 50, ""},
+		{3, 3, "and 47 more errors"},
+		{50, 50, ""},
+		{49, 49, "and 1 more error"},
+	}
+
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%d", tc.max), func(t *testing.T) {
+			var out testPrimitives
+			err := ParseWith(&out, f, Options{ContinueOnError: true, MaxErrors: tc.max})
+
+			var merr *MultiError
+			if !errors.As(err, &merr) {
+				t.Fatalf("wrong error: %#v", err)
+			}
+			if len(merr.Errors()) != tc.wantLen {
+				t.Errorf("len(Errors()) = %d; want %d", len(merr.Errors()), tc.wantLen)
+			}
+			if !errorContains(merr.Errors()[0], "line 2: error parsing int64") {
+				t.Errorf("wrong first error: %v", merr.Errors()[0])
+			}
+
+			lines := strings.Split(err.Error(), "\n")
+			last := lines[len(lines)-1]
+			if tc.wantMore == "" && strings.HasPrefix(last, "and ") {
+				t.Errorf("unexpected summary: %q", last)
+			}
+			if tc.wantMore != "" && last != tc.wantMore {
+				t.Errorf("\nwant: %q\nout:  %q", tc.wantMore, last)
+			}
+
+			// Lines with errors don't change the field, and parsing continues.
+			if out.Int64 != 1 || out.Str != "last" {
+				t.Errorf("wrong: %#v", out)
+			}
+		})
+	}
+
+	t.Run("stop", func(t *testing.T) {
+		var out testPrimitives
+		err := ParseWith(&out, f, Options{MaxErrors: 3})
+		var merr *MultiError
+		if errors.As(err, &merr) {
+			t.Errorf("MultiError without ContinueOnError: %v", err)
+		}
+		if out.Str != "" {
+			t.Errorf("continued parsing: %#v", out)
+		}
+	})
+}
+
+func TestParseAll(t *testing.T) {
+	f := testfile("int64 1\nint64 x\nu-int64 -1\nstr ok\nnope 1\nfloat64 2.5\n")
+	defer rm(t, f)
+
+	var out testPrimitives
+	err := ParseAll(&out, f, nil)
+
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("wrong error: %#v", err)
+	}
+	want := []string{
+		"line 2: error parsing int64",
+		"line 3: error parsing u-int64",
+		"line 5: error parsing nope: unknown option",
+	}
+	if len(merr.Errors()) != len(want) {
+		t.Fatalf("wrong errors: %v", err)
+	}
+	for i := range want {
+		if !errorContains(merr.Errors()[i], want[i]) {
+			t.Errorf("error %d wrong\nwant: %v\nout:  %v", i, want[i], merr.Errors()[i])
+		}
+	}
+	if out.Int64 != 1 || out.UInt64 != 0 || out.Str != "ok" || out.Float64 != 2.5 {
+		t.Errorf("wrong: %#v", out)
+	}
+}
+
+// Defaults and required fields are still processed after an error with
+// ContinueOnError.
+func TestParseAllDefaults(t *testing.T) {
+	type config struct {
+		Port    int64  `default:"8080"`
+		Host    string `sconfig:",required"`
+		Timeout int64  `default:"x"`
+		Name    string
+	}
+
+	f := testfile("port x\nname ok")
+	defer rm(t, f)
+
+	var out config
+	err := ParseAll(&out, f, nil)
+	var merr *MultiError
+	if !errors.As(err, &merr) {
+		t.Fatalf("wrong error: %#v", err)
+	}
+	want := []string{
+		`line 1: error parsing port`,
+		`invalid default "x" for Timeout`,
+		`missing required options: Host`,
+	}
+	if len(merr.Errors()) != len(want) {
+		t.Fatalf("wrong errors: %v", err)
+	}
+	for i := range want {
+		if !errorContains(merr.Errors()[i], want[i]) {
+			t.Errorf("error %d wrong\nwant: %v\nout:  %v", i, want[i], merr.Errors()[i])
+		}
+	}
+	var rerr *RequiredError
+	if !errors.As(merr.Errors()[2], &rerr) || !reflect.DeepEqual(rerr.Fields, []string{"Host"}) {
+		t.Errorf("wrong RequiredError: %#v", merr.Errors()[2])
+	}
+	if out.Port != 8080 || out.Name != "ok" {
+		t.Errorf("wrong: %#v", out)
+	}
+
+	err = ParseWith(&out, f, Options{ContinueOnError: true, MaxErrors: 1})
+	if !errorContains(err, "and 2 more errors") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	type config struct {
+		Keys  []string `sconfig:",trim=\"'"`
+		Vars  []string `sconfig:",trim=$"`
+		Ports []int64  `sconfig:",trim=:"`
+		Hosts []string `sconfig:",sep=;,trim=<>"`
+		Name  string   `sconfig:",trim=\""`
+	}
+
+	tests := []struct {
+		in   string
+		want config
+	}{
+		{`keys "a" 'b' c "d`, config{Keys: []string{"a", "b", "c", "d"}}},
+		{`keys "a b"`, config{Keys: []string{"a", "b"}}},
+		{`keys "a"x"`, config{Keys: []string{`a"x`}}},
+		{`vars $HOME $$PATH`, config{Vars: []string{"HOME", "PATH"}}},
+		{`ports :80 :443`, config{Ports: []int64{80, 443}}},
+		{`hosts <a>;<b c>`, config{Hosts: []string{"a", "b c"}}},
+		{`name "x"`, config{Name: `"x"`}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestParseMulti(t *testing.T) {
+	f := testfile("port 8080\nhost example.com\ndatabase-host localhost\ndatabase-port 5432\nname x")
+	defer rm(t, f)
+
+	var (
+		server struct {
+			Port int64
+			Host string
+			Name string
+		}
+		db struct {
+			DatabaseHost string
+			DatabasePort int64
+			Name         string
+		}
+	)
+	err := ParseMulti(f, nil, &server, &db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.Port != 8080 || server.Host != "example.com" || server.Name != "x" {
+		t.Errorf("wrong server: %#v", server)
+	}
+	if db.DatabaseHost != "localhost" || db.DatabasePort != 5432 || db.Name != "" {
+		t.Errorf("wrong db: %#v", db)
+	}
+
+	f2 := testfile("port 8080\nunknown x")
+	defer rm(t, f2)
+	err = ParseMulti(f2, nil, &server, &db)
+	if !errorContains(err, "line 2: error parsing unknown: unknown option (field Unknown or Unknowns is missing)") {
+		t.Errorf("wrong error: %v", err)
+	}
+
+	err = ParseMulti(f, nil)
+	if !errorContains(err, "no targets") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	type config struct {
+		Append  []string
+		Replace []string `sconfig:",replace"`
+		Scores  []int64  `sconfig:",replace,len=2"`
+		Unique  []string `sconfig:",replace,dedup"`
+		Handled []string `sconfig:",replace"`
+		Pairs   []struct {
+			K, V string
+		} `sconfig:",replace"`
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"append a b\nappend c", config{Append: []string{"a", "b", "c"}}, ""},
+		{"replace a b", config{Replace: []string{"a", "b"}}, ""},
+		{"replace a b\nreplace c", config{Replace: []string{"c"}}, ""},
+		{"replace a\nappend x\nreplace b\nappend y", config{Append: []string{"x", "y"}, Replace: []string{"b"}}, ""},
+		{"replace a\nreplace !clear b c", config{Replace: []string{"b", "c"}}, ""},
+		{"scores 1\nscores 2 3", config{Scores: []int64{2, 3}}, ""},
+		{"scores 1\nscores 2 3 4", config{}, "too many values: 3 (len: 2)"},
+		{"unique a a\nunique b b", config{Unique: []string{"b"}}, ""},
+		{"pairs a 1\npairs b 2", config{Pairs: []struct{ K, V string }{{"b", "2"}}}, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+
+	t.Run("handler", func(t *testing.T) {
+		f := testfile("handled a\nhandled b")
+		defer rm(t, f)
+
+		out := config{Handled: []string{"x"}}
+		err := Parse(&out, f, Handlers{"Handled": func(v []string) error {
+			out.Handled = append(out.Handled, v...)
+			return nil
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"x", "a", "b"}; !reflect.DeepEqual(out.Handled, want) {
+			t.Errorf("\nwant: %#v\nout:  %#v\n", want, out.Handled)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		f := testfile("scores 1 2\nscores x 3\nunique a")
+		defer rm(t, f)
+
+		var out config
+		err := ParseAll(&out, f, nil)
+		if !errorContains(err, `line 2: error parsing scores: strconv.ParseInt: parsing "x"`) {
+			t.Fatalf("wrong error: %v", err)
+		}
+		want := config{Scores: []int64{1, 2}, Unique: []string{"a"}}
+		if !reflect.DeepEqual(out, want) {
+			t.Errorf("\nwant: %#v\nout:  %#v\n", want, out)
+		}
+	})
+}
+
+func TestLenFill(t *testing.T) {
+	type config struct {
+		Scores  []int64   `sconfig:",len=3"`
+		Weights []float64 `sconfig:",len=3,fill=1.5"`
+		Names   []string  `sconfig:",len=2,fill=none"`
+		Empty   []string  `sconfig:",len=0"`
+		Bad     []int64   `sconfig:",len=2,fill=x"`
+		BadLen  []int64   `sconfig:",len=-1"`
+	}
+
+	tests := []struct {
+		in      string
+		want    config
+		wantErr string
+	}{
+		{"scores 10", config{Scores: []int64{10, 0, 0}}, ""},
+		{"scores 10 20 30", config{Scores: []int64{10, 20, 30}}, ""},
+		{"scores 10 20 30 40", config{}, "too many values: 4 (len: 3)"},
+		{"scores 10\nscores 20", config{}, "line 2: error parsing scores: too many values: 4 (len: 3)"},
+		{"weights 2", config{Weights: []float64{2, 1.5, 1.5}}, ""},
+		{"names a", config{Names: []string{"a", "none"}}, ""},
+		{"names a b", config{Names: []string{"a", "b"}}, ""},
+		{"names a b c", config{}, "too many values: 3 (len: 2)"},
+		{"empty a", config{}, "too many values: 1 (len: 0)"},
+		{"bad 1", config{}, `invalid fill "x" in struct tag`},
+		{"bad-len 1", config{}, `invalid len "-1" in struct tag`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out config
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if tc.wantErr == "" && !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestEffective(t *testing.T) {
+	type db struct {
+		Host string
+		Port int64
+	}
+	type config struct {
+		BaseURL  string
+		Port     int64
+		Debug    bool
+		Ratio    float64
+		Timeout  time.Duration
+		Hosts    []string
+		Headers  []Pair
+		Comment  string
+		Database *db
+		Limits   map[string]string
+		Rows     [][]int64
+		Unset    string
+	}
+
+	base := testfile("base-url http://example.com\nport 8080\nhosts a b\n" +
+		"timeout 5s\ndatabase.host localhost\ndatabase.port 5432\nlimits.x 1")
+	defer rm(t, base)
+	override := testfile("port 9000\ndebug yes\nratio 0.25\nhosts c\n" +
+		"headers a=b c\ncomment x\\#y \\\\ z \\ w\ndatabase.port 5433\nlimits.y 2\n" +
+		"rows 1 2\nrows 3")
+	defer rm(t, override)
+
+	var c config
+	if err := Parse(&c, base, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := Parse(&c, override, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Effective(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "base-url http://example.com\nport 9000\ndebug true\nratio 0.25\n" +
+		"timeout 5s\nhosts a b c\nheaders a=b c\ncomment x\\#y \\\\ z \\ w\n" +
+		"database.host localhost\ndatabase.port 5433\nlimits.x 1\nlimits.y 2\n" +
+		"rows 1 2\nrows 3\n"
+	if string(out) != want {
+		t.Errorf("\nwant:\n%s\nout:\n%s", want, out)
+	}
+
+	f := testfile(string(out))
+	defer rm(t, f)
+	var c2 config
+	if err := Parse(&c2, f, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, c2) {
+		t.Errorf("round-trip failed\nwant: %#v\nout:  %#v\n", c, c2)
+	}
+
+	_, err = Effective(&struct{ C chan int }{C: make(chan int)})
+	if !errorContains(err, "don't know how to marshal the type chan int") {
+		t.Errorf("wrong error: %v", err)
+	}
+}
+
+// The output of Effective() for types with a type handler is read back by
+// Parse() as the same value.
+func TestEffectiveRoundTrip(t *testing.T) {
+	defer func() {
+		handlersMu.Lock()
+		delete(typeHandlers, "sconfig.testMode")
+		delete(typeHandlers, "[]sconfig.testMode")
+		delete(marshalers, "sconfig.testMode")
+		handlersMu.Unlock()
+	}()
+	RegisterEnum("sconfig.testMode", map[string]interface{}{
+		"fast":         modeFast,
+		"safe":         modeSafe,
+		"experimental": modeExperimental,
+	})
+
+	type config struct {
+		Mode    testMode
+		Modes   []testMode
+		Timeout time.Duration
+		Hosts   []string
+		Debug   bool
+	}
+
+	f := testfile("mode safe\nmodes fast,experimental\ntimeout 1m30s\nhosts a b\ndebug yes")
+	defer rm(t, f)
+	var c config
+	if err := Parse(&c, f, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Effective(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "mode safe\nmodes fast experimental\ntimeout 1m30s\nhosts a b\ndebug true\n"
+	if string(out) != want {
+		t.Errorf("\nwant:\n%s\nout:\n%s", want, out)
+	}
+
+	f2 := testfile(string(out))
+	defer rm(t, f2)
+	var c2 config
+	if err := Parse(&c2, f2, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, c2) {
+		t.Errorf("round-trip failed\nwant: %#v\nout:  %#v\n", c, c2)
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	inc := testfile("port 9000")
+	defer rm(t, inc)
+	f := testfile("host example.com\nsource " + inc + "\nhosts a\nhosts b\nlimits.x 1\nhandled x")
+	defer rm(t, f)
+
+	var c struct {
+		Host    string
+		Port    int64
+		Debug   bool `default:"true"`
+		Hosts   []string
+		Limits  map[string]string
+		Handled string
+		Unset   string
+		Sub     struct{ A string }
+	}
+	prov := make(map[string]Source)
+	err := ParseWith(&c, f, Options{
+		Provenance: prov,
+		Handlers:   Handlers{"Handled": func([]string) error { return nil }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]Source{
+		"Host":     {File: f, Line: 1, Kind: SourceFile},
+		"Port":     {File: inc, Line: 1, Kind: SourceFile},
+		"Debug":    {Kind: SourceDefault},
+		"Hosts":    {File: f, Line: 4, Kind: SourceFile},
+		"Limits.x": {File: f, Line: 5, Kind: SourceFile},
+		"Handled":  {File: f, Line: 6, Kind: SourceHandler},
+		"Unset":    {Kind: SourceUnset},
+		"Sub.A":    {Kind: SourceUnset},
+	}
+	if !reflect.DeepEqual(prov, want) {
+		t.Errorf("\nwant: %#v\nout:  %#v", want, prov)
+	}
+
+	if s := SourceHandler.String(); s != "handler" {
+		t.Errorf("wrong string: %q", s)
+	}
+}
+
+func TestQualifiedName(t *testing.T) {
+	type local []string
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{"", "string"},
+		{[]int64{}, "[]int64"},
+		{&time.Time{}, "*time.Time"},
+		{[]*json.Decoder{}, "[]*encoding/json.Decoder"},
+		{local{}, "zgo.at/sconfig.local"},
+		{map[string]string{}, "map[string]string"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.want, func(t *testing.T) {
+			out := qualifiedName(reflect.TypeOf(tc.in))
+			if out != tc.want {
+				t.Errorf("\nwant: %s\nout:  %s", tc.want, out)
+			}
+		})
+	}
+}
+
+func TestSourceErrorFile(t *testing.T) {
+	inc := testfile("port 1\n\nport x")
+	defer rm(t, inc)
+	f := testfile("source " + inc)
+	defer rm(t, f)
+
+	var c struct{ Port int64 }
+	err := Parse(&c, f, nil)
+	want := inc + " line 3: error parsing port:"
+	if !errorContains(err, want) {
+		t.Errorf("wrong error\nwant: %s\nout:  %v", want, err)
+	}
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.File != inc || perr.Line != 3 {
+		t.Errorf("wrong ParseError: %#v", perr)
+	}
+
+	inc2 := testfile("\nwoot x")
+	defer rm(t, inc2)
+	f2 := testfile("port 1\nsource " + inc2)
+	defer rm(t, f2)
+	err = Parse(&c, f2, nil)
+	want = inc2 + " line 2: error parsing woot: unknown option"
+	if !errorContains(err, want) {
+		t.Errorf("wrong error\nwant: %s\nout:  %v", want, err)
+	}
 }
@@ -0,0 +1,214 @@
+package sconfig
+
+// This file contains the handling of the "sconfig" struct tag.
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Tag is a parsed "sconfig" struct tag.
+//
+// Options which can be used for all fields:
+//
+//     required   The key must be in the file; see Parse().
+//     emitempty  Write the field with Marshal() even if it's the zero value.
+//
+// Options which can be used for all slice fields:
+//
+//     dedup      Remove duplicate values, keeping the first one.
+//     max=n      Error if there are more than n values (after removing
+//                duplicates with dedup).
+//     len=n      The slice must have exactly n values: it's an error if there
+//                are more, and it's padded with the zero value (or fill) if
+//                there are fewer. This applies after every line, so a second
+//                line for the same key is an error (unless replace is set).
+//     fill=v     Value to pad with for len; this is parsed in the same way as
+//                the slice's values.
+//     replace    Every line replaces the values from previous lines, instead
+//                of appending to them. This doesn't apply to fields with a
+//                Handler.
+//     trim=s     Remove all leading and trailing characters in s from every
+//                value; e.g. `sconfig:",trim=\"'"` removes quotes. This is
+//                done after the line is split in to values, and there is no
+//                special handling of quotes: "a b" is still two values.
+//
+// Options for integer fields:
+//
+//     count      Every line with just the key and no value increments the
+//                field by one, so "verbose" on three lines sets it to 3. A line
+//                with a value sets the field as usual, and following lines
+//                without a value increment from there.
+//
+// The tag is a comma-separated list, where the first item is the name and the
+// rest are options, which can have a value after a "=":
+//
+//     Field string `sconfig:"name,opt1,opt2=value"`
+//
+// The name can be empty (`sconfig:",opt1"`), or omitted entirely if the first
+// option has a value (`sconfig:"opt2=value"`).
+//
+// A comma directly after the "=" is part of the value, so `sconfig:"sep=,"`
+// sets the "sep" option to ",".
+type Tag struct {
+	Name    string
+	Options map[string]string
+}
+
+// TagHandler is like a TypeHandler, but also gets the field's struct tag.
+type TagHandler func(Tag, []string) (interface{}, error)
+
+// tagHandlers are all the registered tag handlers.
+var tagHandlers = make(map[string]TagHandler)
+
+// RegisterTagType sets a TagHandler for a type; this is used instead of any
+// handlers set with RegisterType(). The type can be qualified with the full
+// package path, as with RegisterType().
+//
+// This is useful for types which need some extra information to parse the
+// value; for example the handlers/text/template package uses a "delims" option
+// to set the template delimiters.
+func RegisterTagType(typ string, fun TagHandler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	tagHandlers[typ] = fun
+}
+
+// Has reports if the option is set.
+func (t Tag) Has(opt string) bool {
+	_, ok := t.Options[opt]
+	return ok
+}
+
+// Get the value of an option, or an empty string if it's not set.
+func (t Tag) Get(opt string) string {
+	return t.Options[opt]
+}
+
+func parseTag(st reflect.StructTag) Tag {
+	t := Tag{Options: make(map[string]string)}
+	tag, ok := st.Lookup("sconfig")
+	if !ok {
+		return t
+	}
+
+	opts := strings.Split(tag, ",")
+	if !strings.Contains(opts[0], "=") {
+		t.Name = opts[0]
+		opts = opts[1:]
+	}
+	for i := 0; i < len(opts); i++ {
+		o := opts[i]
+		if o == "" {
+			continue
+		}
+		// "sep=," has a comma as the value.
+		if strings.HasSuffix(o, "=") && i+1 < len(opts) {
+			o += "," + opts[i+1]
+			i++
+		}
+		kv := strings.SplitN(o, "=", 2)
+		if len(kv) == 1 {
+			t.Options[kv[0]] = ""
+		} else {
+			t.Options[kv[0]] = kv[1]
+		}
+	}
+	return t
+}
+
+// trim the values of slice fields with the "trim" tag option.
+func trim(field reflect.Value, tag Tag, values []string) []string {
+	cutset := tag.Get("trim")
+	if cutset == "" || field.Kind() != reflect.Slice {
+		return values
+	}
+	r := make([]string, len(values))
+	for i := range values {
+		r[i] = strings.Trim(values[i], cutset)
+	}
+	return r
+}
+
+// sliceOptions applies the options for slice fields from the tag.
+func sliceOptions(val reflect.Value, tag Tag) (reflect.Value, error) {
+	if tag.Has("dedup") {
+		val = dedup(val)
+	}
+	if tag.Has("len") {
+		var err error
+		val, err = fill(val, tag)
+		if err != nil {
+			return val, err
+		}
+	}
+	if tag.Has("max") {
+		max, err := strconv.Atoi(tag.Get("max"))
+		if err != nil {
+			return val, fmt.Errorf("invalid max %q in struct tag", tag.Get("max"))
+		}
+		if val.Len() > max {
+			if tag.Has("dedup") {
+				return val, fmt.Errorf("too many unique values: %d (max: %d)", val.Len(), max)
+			}
+			return val, fmt.Errorf("too many values: %d (max: %d)", val.Len(), max)
+		}
+	}
+	return val, nil
+}
+
+// fill the slice to the length in the "len" tag option.
+func fill(val reflect.Value, tag Tag) (reflect.Value, error) {
+	n, err := strconv.Atoi(tag.Get("len"))
+	if err != nil || n < 0 {
+		return val, fmt.Errorf("invalid len %q in struct tag", tag.Get("len"))
+	}
+	if val.Len() > n {
+		return val, fmt.Errorf("too many values: %d (len: %d)", val.Len(), n)
+	}
+	if val.Len() == n {
+		return val, nil
+	}
+
+	elem := reflect.New(val.Type().Elem()).Elem()
+	if tag.Has("fill") {
+		has, err := setFromTypeHandler(&elem, Tag{}, []string{tag.Get("fill")})
+		if !has {
+			err = fmt.Errorf("don't know how to set fields of the type %s", elem.Type())
+		}
+		if err != nil {
+			return val, fmt.Errorf("invalid fill %q in struct tag: %v", tag.Get("fill"), err)
+		}
+	}
+	for val.Len() < n {
+		val = reflect.Append(val, elem)
+	}
+	return val, nil
+}
+
+// dedup removes duplicate values from the slice, keeping the first one.
+func dedup(val reflect.Value) reflect.Value {
+	r := reflect.MakeSlice(val.Type(), 0, val.Len())
+	comparable := val.Type().Elem().Comparable()
+	seen := make(map[interface{}]struct{})
+outer:
+	for i := 0; i < val.Len(); i++ {
+		v := val.Index(i)
+		if comparable {
+			if _, ok := seen[v.Interface()]; ok {
+				continue
+			}
+			seen[v.Interface()] = struct{}{}
+		} else {
+			for j := 0; j < r.Len(); j++ {
+				if reflect.DeepEqual(r.Index(j).Interface(), v.Interface()) {
+					continue outer
+				}
+			}
+		}
+		r = reflect.Append(r, v)
+	}
+	return r
+}
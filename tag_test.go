@@ -0,0 +1,36 @@
+package sconfig
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		in   reflect.StructTag
+		want Tag
+	}{
+		{``, Tag{Options: map[string]string{}}},
+		{`json:"x"`, Tag{Options: map[string]string{}}},
+		{`sconfig:""`, Tag{Options: map[string]string{}}},
+		{`sconfig:"name"`, Tag{Name: "name", Options: map[string]string{}}},
+		{`sconfig:"name,a"`, Tag{Name: "name", Options: map[string]string{"a": ""}}},
+		{`sconfig:",a,b=1"`, Tag{Options: map[string]string{"a": "", "b": "1"}}},
+		{`sconfig:"delims=<< >>"`, Tag{Options: map[string]string{"delims": "<< >>"}}},
+		{`sconfig:"a=x=y,,b"`, Tag{Options: map[string]string{"a": "x=y", "b": ""}}},
+		{`sconfig:"sep=,"`, Tag{Options: map[string]string{"sep": ","}}},
+		{`sconfig:"sep=,,b"`, Tag{Options: map[string]string{"sep": ",", "b": ""}}},
+		{`sconfig:"name,sep=:"`, Tag{Name: "name", Options: map[string]string{"sep": ":"}}},
+		{`sconfig:",replace"`, Tag{Options: map[string]string{"replace": ""}}},
+		{`sconfig:"name,replace,dedup"`, Tag{Name: "name", Options: map[string]string{"replace": "", "dedup": ""}}},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.in), func(t *testing.T) {
+			out := parseTag(tc.in)
+			if !reflect.DeepEqual(out, tc.want) {
+				t.Errorf("\nwant: %#v\nout:  %#v\n", tc.want, out)
+			}
+		})
+	}
+}
@@ -5,14 +5,28 @@ package sconfig
 import (
 	"errors"
 	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Errors used by the validation handlers.
 var (
 	errValidateNoValue         = errors.New("does not accept any values")
 	errValidateSingleValue     = errors.New("must have exactly one value")
+	errValidateAtMostOneValue  = errors.New("must have at most one value")
 	errValidateValueLimitMore  = "must have more than %v values (has: %v)"
 	errValidateValueLimitFewer = "must have fewer than %v values (has: %v)"
+	errValidateSorted          = "must be sorted: %q at position %v is smaller than %q"
+	errValidateUnique          = "must be unique: %q at position %v is a duplicate"
+	errValidateUnits           = "invalid unit %q in %q (allowed: %s)"
+	errValidateMinDuration     = "duration %s is shorter than the minimum of %s"
+	errValidateIntRange        = "value %d out of range [%d, %d]"
+	errValidateFloatRange      = "value %v out of range [%v, %v]"
+	errValidateOneOf           = "%q is not one of: %s"
+	errValidateRegexp          = "%q does not match %s"
 )
 
 // ValidateNoValue returns a type handler that will return an error if there are
@@ -37,6 +51,17 @@ func ValidateSingleValue() TypeHandler {
 	}
 }
 
+// ValidateAtMostOneValue returns a type handler that will return an error if
+// there is more than one value; no value is allowed.
+func ValidateAtMostOneValue() TypeHandler {
+	return func(v []string) (interface{}, error) {
+		if len(v) > 1 {
+			return nil, errValidateAtMostOneValue
+		}
+		return v, nil
+	}
+}
+
 // ValidateValueLimit returns a type handler that will return an error if there
 // either more values than max, or fewer values than min.
 func ValidateValueLimit(min, max int) TypeHandler {
@@ -51,3 +76,174 @@ func ValidateValueLimit(min, max int) TypeHandler {
 		}
 	}
 }
+
+// ValidateSorted returns a type handler that will return an error if the values
+// aren't sorted in ascending order. Values are compared as numbers if both are
+// numbers, so "9" is before "10".
+func ValidateSorted() TypeHandler {
+	return func(v []string) (interface{}, error) {
+		for i := 1; i < len(v); i++ {
+			if less(v[i], v[i-1]) {
+				return nil, fmt.Errorf(errValidateSorted, v[i], i+1, v[i-1])
+			}
+		}
+		return v, nil
+	}
+}
+
+// ValidateUnique returns a type handler that will return an error if any value
+// appears more than once.
+func ValidateUnique() TypeHandler {
+	return func(v []string) (interface{}, error) {
+		seen := make(map[string]struct{}, len(v))
+		for i, vv := range v {
+			if _, ok := seen[vv]; ok {
+				return nil, fmt.Errorf(errValidateUnique, vv, i+1)
+			}
+			seen[vv] = struct{}{}
+		}
+		return v, nil
+	}
+}
+
+// ValidateUnits returns a type handler that will return an error if the unit
+// of any value isn't in the list of allowed units.
+//
+// The unit is everything after the last digit, so for "1.5ms" it's "ms", and
+// for "1h30m" it's "m". A value without a unit (e.g. "30") has the unit "", which
+// is only allowed if "" is in the list. Units are case-sensitive.
+func ValidateUnits(allowed ...string) TypeHandler {
+	return func(v []string) (interface{}, error) {
+		for _, vv := range v {
+			unit := vv[strings.LastIndexAny(vv, "0123456789")+1:]
+			if !inList(unit, allowed) {
+				return nil, fmt.Errorf(errValidateUnits, unit, vv, strings.Join(allowed, ", "))
+			}
+		}
+		return v, nil
+	}
+}
+
+// ValidateMinDuration returns a type handler that will return an error if any
+// value is a duration shorter than min. This can be combined with the
+// time.Duration handler to disallow very short intervals:
+//
+//     sconfig.RegisterType("time.Duration", sconfig.ValidateSingleValue(),
+//         sconfig.ValidateMinDuration(time.Second), sconfig.HandleDuration)
+//
+// The values are parsed in the same way as the time.Duration handler, and it's
+// an error if they can't be parsed.
+func ValidateMinDuration(min time.Duration) TypeHandler {
+	return func(v []string) (interface{}, error) {
+		for _, vv := range v {
+			d, err := parseDuration(vv)
+			if err != nil {
+				return nil, err
+			}
+			if d < min {
+				return nil, fmt.Errorf(errValidateMinDuration, d, min)
+			}
+		}
+		return v, nil
+	}
+}
+
+// ValidateIntRange returns a type handler that will return an error if any
+// value is smaller than min or larger than max. For example for a port number:
+//
+//     sconfig.RegisterType("int64", sconfig.ValidateSingleValue(),
+//         sconfig.ValidateIntRange(1, 65535), sconfig.HandleInt64)
+//
+// It's an error if any value isn't an integer.
+func ValidateIntRange(min, max int64) TypeHandler {
+	return func(v []string) (interface{}, error) {
+		for _, vv := range v {
+			n, err := strconv.ParseInt(numeric(vv), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			if n < min || n > max {
+				return nil, fmt.Errorf(errValidateIntRange, n, min, max)
+			}
+		}
+		return v, nil
+	}
+}
+
+// ValidateFloatRange is like ValidateIntRange(), but for floating-point
+// numbers.
+func ValidateFloatRange(min, max float64) TypeHandler {
+	return func(v []string) (interface{}, error) {
+		for _, vv := range v {
+			n, err := strconv.ParseFloat(numeric(vv), 64)
+			if err != nil {
+				return nil, err
+			}
+			if n < min || n > max || math.IsNaN(n) {
+				return nil, fmt.Errorf(errValidateFloatRange, n, min, max)
+			}
+		}
+		return v, nil
+	}
+}
+
+// ValidateOneOf returns a type handler that will return an error if any value
+// isn't in the list of allowed values:
+//
+//     sconfig.RegisterType("main.LogLevel", sconfig.ValidateSingleValue(),
+//         sconfig.ValidateOneOf("debug", "info", "warn", "error"), handleLogLevel)
+//
+// The values are case-sensitive; see ValidateOneOfFold() for a case-insensitive
+// version.
+func ValidateOneOf(allowed ...string) TypeHandler {
+	return validateOneOf(allowed, func(a, b string) bool { return a == b })
+}
+
+// ValidateOneOfFold is like ValidateOneOf(), but compares the values
+// case-insensitively.
+func ValidateOneOfFold(allowed ...string) TypeHandler {
+	return validateOneOf(allowed, strings.EqualFold)
+}
+
+func validateOneOf(allowed []string, eq func(a, b string) bool) TypeHandler {
+	return func(v []string) (interface{}, error) {
+	outer:
+		for _, vv := range v {
+			for _, a := range allowed {
+				if eq(vv, a) {
+					continue outer
+				}
+			}
+			return nil, fmt.Errorf(errValidateOneOf, vv, strings.Join(allowed, ", "))
+		}
+		return v, nil
+	}
+}
+
+// ValidateRegexp returns a type handler that will return an error if any value
+// doesn't match the regular expression. It will panic if the pattern can't be
+// compiled.
+//
+// The pattern isn't anchored, so use ^ and $ to match the full value:
+//
+//     sconfig.ValidateRegexp(`^[a-z0-9.-]+$`)
+func ValidateRegexp(pattern string) TypeHandler {
+	re := regexp.MustCompile(pattern)
+	return func(v []string) (interface{}, error) {
+		for _, vv := range v {
+			if !re.MatchString(vv) {
+				return nil, fmt.Errorf(errValidateRegexp, vv, re)
+			}
+		}
+		return v, nil
+	}
+}
+
+func less(a, b string) bool {
+	na, errA := strconv.ParseFloat(numeric(a), 64)
+	nb, errB := strconv.ParseFloat(numeric(b), 64)
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+	return a < b
+}
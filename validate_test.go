@@ -1,9 +1,11 @@
 package sconfig
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestValidate(t *testing.T) {
@@ -20,6 +22,10 @@ func TestValidate(t *testing.T) {
 		{ValidateSingleValue(), []string{}, errValidateSingleValue},
 		{ValidateSingleValue(), []string{"asd", "zxc"}, errValidateSingleValue},
 
+		{ValidateAtMostOneValue(), []string{}, nil},
+		{ValidateAtMostOneValue(), []string{"qwe"}, nil},
+		{ValidateAtMostOneValue(), []string{"asd", "zxc"}, errValidateAtMostOneValue},
+
 		{ValidateValueLimit(0, 1), []string{}, nil},
 		{ValidateValueLimit(0, 1), []string{"Asd"}, nil},
 		{ValidateValueLimit(0, 1), []string{"zxc", "asd"}, fmt.Errorf(errValidateValueLimitFewer, 1, 2)},
@@ -29,6 +35,66 @@ func TestValidate(t *testing.T) {
 		{ValidateValueLimit(2, 3), []string{"ads", "asd"}, nil},
 		{ValidateValueLimit(2, 3), []string{"ads", "zxc", "qwe"}, nil},
 		{ValidateValueLimit(2, 3), []string{"ads", "zxc", "qwe", "hjkl"}, fmt.Errorf(errValidateValueLimitFewer, 3, 4)},
+
+		{ValidateSorted(), []string{}, nil},
+		{ValidateSorted(), []string{"1"}, nil},
+		{ValidateSorted(), []string{"1", "2", "2", "10"}, nil},
+		{ValidateSorted(), []string{"-5", "0.5", "+3", "1e3"}, nil},
+		{ValidateSorted(), []string{"a", "b", "bb"}, nil},
+		{ValidateSorted(), []string{"1", "10", "9"}, fmt.Errorf(errValidateSorted, "9", 3, "10")},
+		{ValidateSorted(), []string{"b", "a"}, fmt.Errorf(errValidateSorted, "a", 2, "b")},
+
+		{ValidateUnique(), []string{}, nil},
+		{ValidateUnique(), []string{"a", "b", "c"}, nil},
+		{ValidateUnique(), []string{"a", "A"}, nil},
+		{ValidateUnique(), []string{"a", "b", "a", "b"}, fmt.Errorf(errValidateUnique, "a", 3)},
+
+		{ValidateUnits("s", "ms", "m"), []string{}, nil},
+		{ValidateUnits("s", "ms", "m"), []string{"5s", "1.5ms", "1h30m", "10m"}, nil},
+		{ValidateUnits("s", "ms", "m"), []string{"5s", "10MB"}, fmt.Errorf(errValidateUnits, "MB", "10MB", "s, ms, m")},
+		{ValidateUnits("s", "ms", "m"), []string{"5S"}, fmt.Errorf(errValidateUnits, "S", "5S", "s, ms, m")},
+		{ValidateUnits("s", "ms", "m"), []string{"30"}, fmt.Errorf(errValidateUnits, "", "30", "s, ms, m")},
+		{ValidateUnits("s", "ms", "m"), []string{"s"}, nil},
+		{ValidateUnits("s", ""), []string{"30", "30s"}, nil},
+		{ValidateUnits("KB", "MB"), []string{"1.5MB", "2 KB"}, fmt.Errorf(errValidateUnits, " KB", "2 KB", "KB, MB")},
+
+		{ValidateMinDuration(time.Second), []string{"1s"}, nil},
+		{ValidateMinDuration(time.Second), []string{"2m", "1d"}, nil},
+		{ValidateMinDuration(time.Second), []string{"999ms"}, fmt.Errorf(errValidateMinDuration, "999ms", "1s")},
+		{ValidateMinDuration(time.Second), []string{"0s"}, fmt.Errorf(errValidateMinDuration, "0s", "1s")},
+		{ValidateMinDuration(time.Second), []string{"-5m"}, fmt.Errorf(errValidateMinDuration, "-5m0s", "1s")},
+		{ValidateMinDuration(time.Second), []string{"5x"}, errors.New(`unable to parse "5x" as a duration`)},
+
+		{ValidateIntRange(1, 65535), []string{"1"}, nil},
+		{ValidateIntRange(1, 65535), []string{"+8080", "65535"}, nil},
+		{ValidateIntRange(1, 65535), []string{"0"}, fmt.Errorf(errValidateIntRange, 0, 1, 65535)},
+		{ValidateIntRange(1, 65535), []string{"80", "65536"}, fmt.Errorf(errValidateIntRange, 65536, 1, 65535)},
+		{ValidateIntRange(-10, -1), []string{"-11"}, fmt.Errorf(errValidateIntRange, -11, -10, -1)},
+		{ValidateIntRange(1, 65535), []string{"1.5"}, errors.New(`strconv.ParseInt: parsing "1.5": invalid syntax`)},
+
+		{ValidateFloatRange(0, 1), []string{"0", "0.5", "1"}, nil},
+		{ValidateFloatRange(0, 1), []string{"+0.5"}, nil},
+		{ValidateFloatRange(0, 1), []string{"1.01"}, fmt.Errorf(errValidateFloatRange, 1.01, 0, 1)},
+		{ValidateFloatRange(0, 1), []string{"-0.5"}, fmt.Errorf(errValidateFloatRange, -0.5, 0, 1)},
+		{ValidateFloatRange(0, 1), []string{"NaN"}, errors.New("value NaN out of range [0, 1]")},
+		{ValidateFloatRange(0, 1), []string{"x"}, errors.New(`strconv.ParseFloat: parsing "x": invalid syntax`)},
+
+		{ValidateOneOf("debug", "info", "warn", "error"), []string{}, nil},
+		{ValidateOneOf("debug", "info", "warn", "error"), []string{"info"}, nil},
+		{ValidateOneOf("debug", "info", "warn", "error"), []string{"debug", "error"}, nil},
+		{ValidateOneOf("debug", "info", "warn", "error"), []string{"trace"},
+			errors.New(`"trace" is not one of: debug, info, warn, error`)},
+		{ValidateOneOf("debug", "info", "warn", "error"), []string{"info", "INFO"},
+			errors.New(`"INFO" is not one of: debug, info, warn, error`)},
+		{ValidateOneOfFold("debug", "info", "warn", "error"), []string{"INFO", "Warn"}, nil},
+		{ValidateOneOfFold("debug", "info"), []string{"Trace"}, errors.New(`"Trace" is not one of: debug, info`)},
+
+		{ValidateRegexp(`^[a-z0-9.-]+$`), []string{}, nil},
+		{ValidateRegexp(`^[a-z0-9.-]+$`), []string{"example.com", "a-1"}, nil},
+		{ValidateRegexp(`^[a-z0-9.-]+$`), []string{"example.com", "ex ample"},
+			errors.New(`"ex ample" does not match ^[a-z0-9.-]+$`)},
+		{ValidateRegexp(`\d`), []string{"a1b"}, nil},
+		{ValidateRegexp(`\d`), []string{"ab"}, errors.New(`"ab" does not match \d`)},
 	}
 
 	for i, tc := range cases {
@@ -58,3 +124,83 @@ func TestValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateMinDurationChain(t *testing.T) {
+	defer func() {
+		typeHandlers["time.Duration"] = []TypeHandler{ValidateSingleValue(), HandleDuration}
+	}()
+	RegisterType("time.Duration", ValidateSingleValue(), ValidateMinDuration(time.Second), HandleDuration)
+
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr string
+	}{
+		{"poll 500ms", 0, "error parsing poll: duration 500ms is shorter than the minimum of 1s"},
+		{"poll 0s", 0, "duration 0s is shorter than the minimum of 1s"},
+		{"poll 1s", time.Second, ""},
+		{"poll 1m", time.Minute, ""},
+		{"poll 1s 1s", 0, "must have exactly one value"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out struct{ Poll time.Duration }
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if out.Poll != tc.want {
+				t.Errorf("\nwant: %s\nout:  %s\n", tc.want, out.Poll)
+			}
+		})
+	}
+}
+
+func TestValidateRegexpPanic(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic")
+		}
+	}()
+	ValidateRegexp(`(`)
+}
+
+func TestValidateIntRangeChain(t *testing.T) {
+	defer func() {
+		typeHandlers["int64"] = []TypeHandler{ValidateSingleValue(), HandleInt64}
+	}()
+	RegisterType("int64", ValidateSingleValue(), ValidateIntRange(1, 65535), HandleInt64)
+
+	tests := []struct {
+		in      string
+		want    int64
+		wantErr string
+	}{
+		{"port 8080", 8080, ""},
+		{"port +8080", 8080, ""},
+		{"port +0", 0, "value 0 out of range [1, 65535]"},
+		{"port 0", 0, "error parsing port: value 0 out of range [1, 65535]"},
+		{"port 70000", 0, "value 70000 out of range [1, 65535]"},
+		{"port 1 2", 0, "must have exactly one value"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			f := testfile(tc.in)
+			defer rm(t, f)
+
+			var out struct{ Port int64 }
+			err := Parse(&out, f, nil)
+			if !errorContains(err, tc.wantErr) {
+				t.Fatalf("err wrong\nwant: %v\nout:  %v\n", tc.wantErr, err)
+			}
+			if out.Port != tc.want {
+				t.Errorf("\nwant: %d\nout:  %d\n", tc.want, out.Port)
+			}
+		})
+	}
+}